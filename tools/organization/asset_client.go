@@ -1,36 +1,221 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"flag"
 	"fmt"
 	log "github.com/echocat/slf4g"
 	"io"
 	"io/ioutil"
-	"mime"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
+	"time"
 )
 
 var (
-	assetsFolder = flag.String("assets", "assets", "Folder to put downloaded assets to.")
+	assetsFolder      = flag.String("assets", "assets", "Folder to put downloaded assets to.")
+	assetsMaximumSize = flag.Int64("assets-maximumSize", 5*1024*1024, "Maximum size in bytes of a downloaded asset.")
+	assetsTimeout     = flag.Duration("assets-timeout", 30*time.Second, "Maximum time to download one asset, including all retries.")
+	assetsPolicy      = flag.String("assets-policy", string(assetPolicyFail), "What to do if the image of a member or project cannot be downloaded: fail the run, skip the image or use a generated fallback image.")
+	assetsConcurrency = flag.Int("assets-concurrency", 8, "Maximum number of concurrent asset downloads.")
 )
 
+// assetPolicy decides what happens if the image of a member or project cannot
+// be downloaded.
+type assetPolicy string
+
+const (
+	assetPolicyFail     = assetPolicy("fail")
+	assetPolicySkip     = assetPolicy("skip")
+	assetPolicyFallback = assetPolicy("fallback")
+)
+
+func (instance assetPolicy) validate() error {
+	switch instance {
+	case assetPolicyFail, assetPolicySkip, assetPolicyFallback:
+		return nil
+	default:
+		return fmt.Errorf("illegal assets policy '%s'; expected fail, skip or fallback", string(instance))
+	}
+}
+
+// assetTypes contains all accepted types of assets with the extension they
+// are stored with.
+var assetTypes = map[string]string{
+	"image/png":     ".png",
+	"image/jpeg":    ".jpg",
+	"image/gif":     ".gif",
+	"image/webp":    ".webp",
+	"image/avif":    ".avif",
+	"image/svg+xml": ".svg",
+}
+
+// assetOwner is the member or project an asset belongs to. It is used for
+// logging and as seed of generated fallback images.
+type assetOwner struct {
+	kind string
+	seed string
+}
+
+func memberAssetOwner(login string) assetOwner {
+	return assetOwner{kind: "member", seed: login}
+}
+
+func projectAssetOwner(origin, fullname string) assetOwner {
+	return assetOwner{kind: "project", seed: origin + ":" + fullname}
+}
+
+func (instance assetOwner) String() string {
+	return instance.kind + " " + instance.seed
+}
+
+// assetClient downloads assets into the assets folder. Each file is named by
+// the SHA-256 of its content, so the folder is kept across runs and files are
+// only replaced if their content changes. Which source was stored as which
+// file is recorded in the assetManifest.
 type assetClient struct {
-	cache map[string]string
-	mutex sync.Mutex
+	*assetStore
+	client *http.Client
 }
 
-func newAssetClient() *assetClient {
+// assetStore is shared by all assetClients of a run, regardless of the
+// transport they download with.
+type assetStore struct {
+	// cache maps the URL of every downloaded asset to its file. Lookups do
+	// not require any lock.
+	cache sync.Map
+	// inFlight contains the currently running downloads by their URL, so
+	// concurrent requests of the same URL result in only one download.
+	inFlight      map[string]*assetDownload
+	inFlightMutex sync.Mutex
+	// downloads limits the number of concurrent downloads.
+	downloads chan struct{}
+
+	policy      assetPolicy
+	maximumSize int64
+	timeout     time.Duration
+	// offline only uses the assets which are known by the manifest.
+	offline bool
+
+	manifest      *assetManifest
+	manifestMutex sync.Mutex
+}
+
+func newAssetClient(transport http.RoundTripper) *assetClient {
 	return &assetClient{
-		cache: make(map[string]string),
+		assetStore: &assetStore{
+			inFlight:    map[string]*assetDownload{},
+			downloads:   make(chan struct{}, max(*assetsConcurrency, 1)),
+			policy:      assetPolicy(*assetsPolicy),
+			maximumSize: *assetsMaximumSize,
+			timeout:     *assetsTimeout,
+			offline:     *offline,
+		},
+		client: &http.Client{Transport: withoutCache(transport)},
+	}
+}
+
+// withTransport returns a client which downloads with the given transport,
+// for example the one of a source with its own TLS configuration. Everything
+// else is shared with this client.
+func (instance *assetClient) withTransport(transport http.RoundTripper) *assetClient {
+	return &assetClient{
+		assetStore: instance.assetStore,
+		client:     &http.Client{Transport: withoutCache(transport)},
+	}
+}
+
+// retrieve downloads the image of the given owner. If this fails, the policy
+// decides whether this is an error, the image is skipped or a fallback image
+// is used instead.
+func (instance *assetClient) retrieve(owner assetOwner, sourceUrl string) (string, error) {
+	result, err := instance.download(sourceUrl)
+	return instance.applyPolicy(owner, result, err)
+}
+
+func (instance *assetClient) applyPolicy(owner assetOwner, result string, err error) (string, error) {
+	if err == nil {
+		return result, nil
+	}
+
+	switch instance.policy {
+	case assetPolicySkip:
+		log.WithError(err).
+			With("owner", owner).
+			Warn("Cannot retrieve image; it will be skipped.")
+		return "", nil
+	case assetPolicyFallback:
+		log.WithError(err).
+			With("owner", owner).
+			Warn("Cannot retrieve image; a generated one will be used instead.")
+		return instance.retrieveIdenticon(owner.seed)
+	default:
+		return "", fmt.Errorf("cannot retrieve image of %v: %w", owner, err)
+	}
+}
+
+// assetDownload is a download in progress; done is closed once asset and err
+// are set.
+type assetDownload struct {
+	done  chan struct{}
+	asset string
+	err   error
+}
+
+// download returns the asset of the given URL. Each URL is downloaded at most
+// once at the same time; concurrent callers wait for the running download.
+// Failed downloads are not cached and tried again by the next caller.
+func (instance *assetClient) download(sourceUrl string) (string, error) {
+	if cached, ok := instance.cache.Load(sourceUrl); ok {
+		return cached.(string), nil
+	}
+
+	instance.inFlightMutex.Lock()
+	// The download might have been completed since the lookup above.
+	if cached, ok := instance.cache.Load(sourceUrl); ok {
+		instance.inFlightMutex.Unlock()
+		return cached.(string), nil
+	}
+	if running, ok := instance.inFlight[sourceUrl]; ok {
+		instance.inFlightMutex.Unlock()
+		<-running.done
+		return running.asset, running.err
+	}
+	current := &assetDownload{done: make(chan struct{})}
+	instance.inFlight[sourceUrl] = current
+	instance.inFlightMutex.Unlock()
+
+	current.asset, current.err = instance.fetch(sourceUrl)
+	if current.err == nil {
+		instance.cache.Store(sourceUrl, current.asset)
 	}
+
+	instance.inFlightMutex.Lock()
+	delete(instance.inFlight, sourceUrl)
+	instance.inFlightMutex.Unlock()
+	close(current.done)
+
+	return current.asset, current.err
 }
 
-func (instance *assetClient) retrieve(sourceUrl string) (resultAsset string, err error) {
-	instance.mutex.Lock()
-	defer instance.mutex.Unlock()
+func (instance *assetClient) fetch(sourceUrl string) (resultAsset string, err error) {
+	known, isKnown := instance.manifestEntry(sourceUrl)
+	if instance.offline {
+		if !isKnown || !instance.exists(known.Asset) {
+			return "", fmt.Errorf("'%s' was not downloaded before, but running offline", sourceUrl)
+		}
+		instance.remember(sourceUrl, known)
+		return known.Asset, nil
+	}
+
+	instance.downloads <- struct{}{}
+	defer func() {
+		<-instance.downloads
+	}()
 
 	l := log.With("url", sourceUrl)
 
@@ -40,11 +225,21 @@ func (instance *assetClient) retrieve(sourceUrl string) (resultAsset string, err
 		}
 	}()
 
-	if cached := instance.cache[sourceUrl]; cached != "" {
-		return cached, nil
+	ctx := context.Background()
+	if instance.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, instance.timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, sourceUrl, nil)
+	if err != nil {
+		return "", fmt.Errorf("cannot download '%s': %w", sourceUrl, err)
+	}
+	if isKnown && known.ETag != "" && instance.exists(known.Asset) {
+		req.Header.Set("If-None-Match", known.ETag)
 	}
 
-	resp, err := http.Get(sourceUrl)
+	resp, err := instance.client.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("cannot download '%s': %w", sourceUrl, err)
 	}
@@ -56,72 +251,177 @@ func (instance *assetClient) retrieve(sourceUrl string) (resultAsset string, err
 			err = cErr
 		}
 	}()
+	if isKnown && resp.StatusCode == http.StatusNotModified {
+		instance.remember(sourceUrl, known)
+		return known.Asset, nil
+	}
 	if resp.StatusCode != http.StatusOK {
 		return "", fmt.Errorf("unexpected status while try to  download '%s': %d - %s", sourceUrl, resp.StatusCode, resp.Status)
 	}
-	contentType := resp.Header.Get("Content-Type")
-	if contentType == "" {
-		return "", fmt.Errorf("no Content-Type header present try to  download '%s': %d - %s", sourceUrl, resp.StatusCode, resp.Status)
+	if instance.maximumSize > 0 && resp.ContentLength > instance.maximumSize {
+		return "", fmt.Errorf("'%s' has %d bytes which exceeds the maximum of %d bytes", sourceUrl, resp.ContentLength, instance.maximumSize)
 	}
-	ext, err := instance.contentTypeToExt(contentType, sourceUrl)
+
+	asset, hash, contentType, err := instance.store(resp.Body, sourceUrl, resp.Header.Get("Content-Type"))
 	if err != nil {
 		return "", err
 	}
-
-	return instance.retrieveFromReader(resp.Body, sourceUrl, ext)
+	instance.remember(sourceUrl, assetManifestEntry{
+		Asset:       asset,
+		Hash:        hash,
+		ContentType: contentType,
+		ETag:        resp.Header.Get("ETag"),
+	})
+	return asset, nil
 }
 
-func (instance *assetClient) contentTypeToExt(contentType, sourceUrl string) (string, error) {
-	ext, err := mime.ExtensionsByType(contentType)
+// store stores the given source in the assets folder if it is one of the
+// assetTypes and does not exceed the maximumSize. header is the type the
+// source claims to be, if any.
+func (instance *assetClient) store(source io.Reader, sourceRef, header string) (asset, hash, contentType string, err error) {
+	body := source
+	if instance.maximumSize > 0 {
+		// One more byte to detect if the maximum is exceeded.
+		body = io.LimitReader(body, instance.maximumSize+1)
+	}
+	head := make([]byte, 512)
+	n, err := io.ReadFull(body, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", "", "", fmt.Errorf("cannot read '%s': %w", sourceRef, err)
+	}
+	head = head[:n]
+	contentType, ok := sniffAssetType(head)
+	if !ok && header != "" {
+		return "", "", "", fmt.Errorf("'%s' is not a supported image; header says '%s', content looks like '%s'", sourceRef, header, contentType)
+	}
+	if !ok {
+		return "", "", "", fmt.Errorf("'%s' is not a supported image; content looks like '%s'", sourceRef, contentType)
+	}
+
+	asset, hash, err = instance.retrieveFromReader(io.MultiReader(bytes.NewReader(head), body), sourceRef, assetTypes[contentType], instance.maximumSize)
 	if err != nil {
-		return "", fmt.Errorf("illegal Content-Type header present try to  download '%s': %s - %w", sourceUrl, contentType, err)
+		return "", "", "", err
 	}
-	if len(ext) <= 0 {
-		return "", fmt.Errorf("illegal Content-Type header present try to  download '%s': %s", sourceUrl, contentType)
+	return asset, hash, contentType, nil
+}
+
+// sniffAssetType detects the type of the given start of an asset by its
+// content. The result is only ok if it is one of assetTypes.
+func sniffAssetType(head []byte) (contentType string, ok bool) {
+	contentType = http.DetectContentType(head)
+	if i := strings.IndexByte(contentType, ';'); i >= 0 {
+		contentType = contentType[:i]
 	}
+	switch {
+	case len(head) >= 12 && string(head[4:8]) == "ftyp" && (string(head[8:12]) == "avif" || string(head[8:12]) == "avis"):
+		// Not known by http.DetectContentType.
+		contentType = "image/avif"
+	case strings.HasPrefix(contentType, "text/") && isSvg(head):
+		contentType = "image/svg+xml"
+	}
+	_, ok = assetTypes[contentType]
+	return contentType, ok
+}
 
-	for _, candidate := range ext {
-		if candidate == ".jpg" {
-			return candidate, nil
+func isSvg(head []byte) bool {
+	s := strings.TrimSpace(string(head))
+	for {
+		switch {
+		case strings.HasPrefix(s, "<?xml"), strings.HasPrefix(s, "<!--"), strings.HasPrefix(s, "<!DOCTYPE"):
+			i := strings.IndexByte(s, '>')
+			if i < 0 {
+				return false
+			}
+			s = strings.TrimSpace(s[i+1:])
+		default:
+			return strings.HasPrefix(s, "<svg")
 		}
 	}
-
-	return ext[0], nil
 }
 
-func (instance *assetClient) retrieveFromReader(source io.Reader, sourceRef, ext string) (string, error) {
-	if err := os.MkdirAll(*assetsFolder, 755); err != nil {
-		return "", fmt.Errorf("cannot create target folder '%s' to store the '%s' inside: %w", *assetsFolder, sourceRef, err)
+// retrieveFromReader stores the content of the given reader in the assets
+// folder and returns the name of the resulting file and its hash. Content
+// which exceeds maximumSize - if greater than 0 - is not stored.
+func (instance *assetClient) retrieveFromReader(source io.Reader, sourceRef, ext string, maximumSize int64) (asset string, hash string, err error) {
+	if err := os.MkdirAll(*assetsFolder, 0755); err != nil {
+		return "", "", fmt.Errorf("cannot create target folder '%s' to store the '%s' inside: %w", *assetsFolder, sourceRef, err)
 	}
 
 	w, err := ioutil.TempFile(*assetsFolder, "~*"+ext)
 	if err != nil {
-		return "", fmt.Errorf("cannot temporary file: %w", err)
+		return "", "", fmt.Errorf("cannot temporary file: %w", err)
 	}
 	defer func() {
 		_ = w.Close()
+		// Only left if something went wrong.
+		_ = os.Remove(w.Name())
 	}()
 
 	r := newSha256reader(source)
-	if _, err = io.Copy(w, r); err != nil {
-		return "", fmt.Errorf("cannot download '%s' to '%s': %w", sourceRef, w.Name(), err)
+	size, err := io.Copy(w, r)
+	if err != nil {
+		return "", "", fmt.Errorf("cannot download '%s' to '%s': %w", sourceRef, w.Name(), err)
+	}
+	if maximumSize > 0 && size > maximumSize {
+		return "", "", fmt.Errorf("'%s' exceeds the maximum of %d bytes", sourceRef, maximumSize)
 	}
 	if err := w.Close(); err != nil {
-		return "", fmt.Errorf("cannot closed '%s' after downloaded from '%s': %w", w.Name(), sourceRef, err)
+		return "", "", fmt.Errorf("cannot closed '%s' after downloaded from '%s': %w", w.Name(), sourceRef, err)
 	}
 
-	target := filepath.Join(*assetsFolder, fmt.Sprintf("%s%s", r.SumString(), ext))
+	hash = r.SumString()
+	target := filepath.Join(*assetsFolder, fmt.Sprintf("%s%s", hash, ext))
 
 	if err := os.Rename(w.Name(), target); err != nil {
-		return "", fmt.Errorf("cannot rename '%s' to '%s' after downloaded from '%s': %w", w.Name(), target, sourceRef, err)
+		return "", "", fmt.Errorf("cannot rename '%s' to '%s' after downloaded from '%s': %w", w.Name(), target, sourceRef, err)
+	}
+
+	return filepath.Base(target), hash, nil
+}
+
+// retrieveFromLocation retrieves the image of the given owner either from
+// the given URL or - if location is not a URL - from the local file. Relative
+// files are resolved against baseDirectory. Like retrieve the policy decides
+// what happens if this fails.
+func (instance *assetClient) retrieveFromLocation(owner assetOwner, location, baseDirectory string) (string, error) {
+	if strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://") {
+		return instance.retrieve(owner, location)
+	}
+
+	file := location
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(baseDirectory, file)
+	}
+	result, err := instance.retrieveFromFile(file)
+	return instance.applyPolicy(owner, result, err)
+}
+
+func (instance *assetClient) retrieveFromFile(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", fmt.Errorf("cannot open '%s': %w", file, err)
 	}
+	defer func() {
+		_ = f.Close()
+	}()
 
-	return filepath.Base(target), nil
+	asset, hash, contentType, err := instance.store(f, file, "")
+	if err != nil {
+		return "", err
+	}
+	instance.remember(file, assetManifestEntry{
+		Asset:       asset,
+		Hash:        hash,
+		ContentType: contentType,
+	})
+	return asset, nil
 }
 
-func (instance *assetClient) cleanTarget() error {
-	if err := os.RemoveAll(*assetsFolder); err != nil && !os.IsNotExist(err) {
-		return err
+// exists returns true if the given asset is present in the assets folder.
+func (instance *assetClient) exists(asset string) bool {
+	if asset == "" {
+		return false
 	}
-	return nil
+	_, err := os.Stat(filepath.Join(*assetsFolder, asset))
+	return err == nil
 }
@@ -0,0 +1,350 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing/iotest"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type assetClientSuite struct{}
+
+const pngSignature = "\x89PNG\r\n\x1a\n"
+
+var _ = Suite(&assetClientSuite{})
+
+func (s *assetClientSuite) TestNamesFilesByContent(c *C) {
+	*assetsFolder = c.MkDir()
+	instance := newAssetClient(nil)
+
+	// Readers which return the last bytes together with io.EOF.
+	a, _, err := instance.retrieveFromReader(iotest.DataErrReader(strings.NewReader("a")), "a", ".png", 0)
+	c.Assert(err, IsNil)
+	b, _, err := instance.retrieveFromReader(iotest.DataErrReader(strings.NewReader("b")), "b", ".png", 0)
+	c.Assert(err, IsNil)
+
+	c.Assert(a, Equals, "ca978112ca1bbdcafac231b39a23dc4da786eff8147c4e72b9807785afee48bb.png")
+	c.Assert(b, Not(Equals), a)
+}
+
+func (s *assetClientSuite) TestRevalidatesKnownAssetsWithETag(c *C) {
+	*assetsFolder = c.MkDir()
+	var downloads int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		atomic.AddInt32(&downloads, 1)
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write([]byte(pngSignature + "content"))
+	}))
+	defer server.Close()
+
+	first := newAssetClient(http.DefaultTransport)
+	asset, err := first.retrieve(memberAssetOwner("jdoe"), server.URL+"/avatar")
+	c.Assert(err, IsNil)
+	c.Assert(first.collectGarbage(organization{
+		Members: members{{ImageAsset: asset}},
+	}, 0), IsNil)
+
+	// A new run which reads the manifest of the previous one.
+	second := newAssetClient(http.DefaultTransport)
+	actual, err := second.retrieve(memberAssetOwner("jdoe"), server.URL+"/avatar")
+	c.Assert(err, IsNil)
+	c.Assert(actual, Equals, asset)
+	c.Assert(atomic.LoadInt32(&downloads), Equals, int32(1))
+
+	entry, ok := second.manifestEntry(server.URL + "/avatar")
+	c.Assert(ok, Equals, true)
+	c.Assert(entry.ContentType, Equals, "image/png")
+	c.Assert(entry.ETag, Equals, `"v1"`)
+	c.Assert(entry.Asset, Equals, entry.Hash+".png")
+}
+
+func (s *assetClientSuite) TestCollectGarbageRespectsGracePeriod(c *C) {
+	*assetsFolder = c.MkDir()
+	old := time.Now().Add(-48 * time.Hour)
+	for _, name := range []string{"a.png", "b.png", "c.png", "d.png"} {
+		file := filepath.Join(*assetsFolder, name)
+		c.Assert(os.WriteFile(file, []byte(name), 0644), IsNil)
+		c.Assert(os.Chtimes(file, old, old), IsNil)
+	}
+	instance := newAssetClient(nil)
+	// Seen recently by the manifest, but no longer referenced.
+	instance.remember("https://example.org/d", assetManifestEntry{Asset: "d.png"})
+	instance.remember("https://example.org/b", assetManifestEntry{Asset: "b.png"})
+	instance.manifest.Entries["https://example.org/b"] = assetManifestEntry{Asset: "b.png", LastSeen: old}
+
+	c.Assert(instance.collectGarbage(organization{
+		Projects: projects{{ImageAsset: pString("a.png")}},
+		Members:  members{{Accounts: []memberAccount{{ImageAsset: "c.png"}}}},
+	}, 24*time.Hour), IsNil)
+
+	entries, err := os.ReadDir(*assetsFolder)
+	c.Assert(err, IsNil)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	c.Assert(names, DeepEquals, []string{assetManifestFile, "a.png", "c.png", "d.png"})
+
+	manifest, err := loadAssetManifest(filepath.Join(*assetsFolder, assetManifestFile))
+	c.Assert(err, IsNil)
+	c.Assert(manifest.Entries, HasLen, 1)
+	c.Assert(manifest.Entries["https://example.org/d"].Asset, Equals, "d.png")
+}
+
+func (s *assetClientSuite) TestDetectsTypeByContent(c *C) {
+	*assetsFolder = c.MkDir()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/avatar":
+			// Wrong header and unknown to mime.ExtensionsByType.
+			w.Header().Set("Content-Type", "application/x-unknown")
+			_, _ = w.Write([]byte(pngSignature + "content"))
+		case "/logo":
+			w.Header().Set("Content-Type", "text/plain")
+			_, _ = w.Write([]byte("<?xml version=\"1.0\"?>\n<!-- logo -->\n<svg xmlns=\"http://www.w3.org/2000/svg\"/>"))
+		default:
+			w.Header().Set("Content-Type", "image/png")
+			_, _ = w.Write([]byte("<html>not found</html>"))
+		}
+	}))
+	defer server.Close()
+	instance := newAssetClient(http.DefaultTransport)
+
+	actual, err := instance.retrieve(memberAssetOwner("jdoe"), server.URL+"/avatar")
+	c.Assert(err, IsNil)
+	c.Assert(actual, Matches, "[0-9a-f]{64}\\.png")
+
+	actual, err = instance.retrieve(projectAssetOwner("github", "echocat/foo"), server.URL+"/logo")
+	c.Assert(err, IsNil)
+	c.Assert(actual, Matches, "[0-9a-f]{64}\\.svg")
+
+	_, err = instance.retrieve(memberAssetOwner("jane"), server.URL+"/other")
+	c.Assert(err, ErrorMatches, "cannot retrieve image of member jane: '.+/other' is not a supported image; header says 'image/png', content looks like 'text/html'")
+}
+
+func (s *assetClientSuite) TestAppliesPolicy(c *C) {
+	*assetsFolder = c.MkDir()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/slow":
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+		default:
+			// Without Content-Length to hit the limit while reading.
+			w.(http.Flusher).Flush()
+			_, _ = w.Write([]byte(pngSignature + strings.Repeat("x", 100)))
+		}
+	}))
+	defer server.Close()
+	instance := newAssetClient(http.DefaultTransport)
+	instance.maximumSize = 50
+	instance.timeout = 50 * time.Millisecond
+
+	_, err := instance.retrieve(memberAssetOwner("jdoe"), server.URL+"/large")
+	c.Assert(err, ErrorMatches, "cannot retrieve image of member jdoe: '.+/large' exceeds the maximum of 50 bytes")
+	_, err = instance.retrieve(memberAssetOwner("jdoe"), server.URL+"/slow")
+	c.Assert(err, ErrorMatches, "cannot retrieve image of member jdoe: .*context deadline exceeded.*")
+
+	instance.policy = assetPolicySkip
+	actual, err := instance.retrieve(memberAssetOwner("jdoe"), server.URL+"/large")
+	c.Assert(err, IsNil)
+	c.Assert(actual, Equals, "")
+
+	instance.policy = assetPolicyFallback
+	actual, err = instance.retrieve(memberAssetOwner("jdoe"), server.URL+"/slow")
+	c.Assert(err, IsNil)
+	identicon, err := instance.retrieveIdenticon("jdoe")
+	c.Assert(err, IsNil)
+	c.Assert(actual, Equals, identicon)
+
+	// Nothing but the identicon is left.
+	entries, err := os.ReadDir(*assetsFolder)
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 1)
+}
+
+func (s *assetClientSuite) TestKeepsExistingAssetOfOversizedContent(c *C) {
+	*assetsFolder = c.MkDir()
+	content := pngSignature + strings.Repeat("x", 100)
+	instance := newAssetClient(nil)
+	existing, _, err := instance.retrieveFromReader(strings.NewReader(content), "existing", ".png", 0)
+	c.Assert(err, IsNil)
+
+	instance.maximumSize = 50
+	_, _, err = instance.retrieveFromReader(strings.NewReader(content), "large", ".png", instance.maximumSize)
+	c.Assert(err, ErrorMatches, "'large' exceeds the maximum of 50 bytes")
+	c.Assert(instance.exists(existing), Equals, true)
+	entries, err := os.ReadDir(*assetsFolder)
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 1)
+}
+
+func (s *assetClientSuite) TestRetrievesLocalFilesLikeDownloads(c *C) {
+	*assetsFolder = c.MkDir()
+	directory := c.MkDir()
+	c.Assert(os.WriteFile(filepath.Join(directory, "logo.dat"), []byte(pngSignature+"content"), 0644), IsNil)
+	c.Assert(os.WriteFile(filepath.Join(directory, "notes.png"), []byte("just text"), 0644), IsNil)
+	c.Assert(os.WriteFile(filepath.Join(directory, "large.png"), []byte(pngSignature+strings.Repeat("x", 100)), 0644), IsNil)
+	instance := newAssetClient(nil)
+	instance.maximumSize = 50
+	owner := projectAssetOwner("static", "foo")
+
+	// The type is detected by the content, not by the extension.
+	actual, err := instance.retrieveFromLocation(owner, "logo.dat", directory)
+	c.Assert(err, IsNil)
+	c.Assert(actual, Matches, "[0-9a-f]{64}\\.png")
+
+	_, err = instance.retrieveFromLocation(owner, "notes.png", directory)
+	c.Assert(err, ErrorMatches, "cannot retrieve image of project static:foo: '.+/notes.png' is not a supported image; content looks like 'text/plain'")
+	_, err = instance.retrieveFromLocation(owner, "large.png", directory)
+	c.Assert(err, ErrorMatches, "cannot retrieve image of project static:foo: '.+/large.png' exceeds the maximum of 50 bytes")
+
+	instance.policy = assetPolicySkip
+	actual, err = instance.retrieveFromLocation(owner, "missing.png", directory)
+	c.Assert(err, IsNil)
+	c.Assert(actual, Equals, "")
+}
+
+func (s *assetClientSuite) TestWithTransportSharesTheStore(c *C) {
+	*assetsFolder = c.MkDir()
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(pngSignature + "content"))
+	}))
+	defer server.Close()
+	instance := newAssetClient(http.DefaultTransport)
+
+	// The certificate of the server is not trusted by default.
+	_, err := instance.download(server.URL + "/avatar")
+	c.Assert(err, ErrorMatches, ".*certificate.*")
+
+	actual, err := instance.withTransport(server.Client().Transport).download(server.URL + "/avatar")
+	c.Assert(err, IsNil)
+
+	cached, err := instance.download(server.URL + "/avatar")
+	c.Assert(err, IsNil)
+	c.Assert(cached, Equals, actual)
+	_, known := instance.manifestEntry(server.URL + "/avatar")
+	c.Assert(known, Equals, true)
+}
+
+func (s *assetClientSuite) TestDoesNotCacheAssets(c *C) {
+	*assetsFolder = c.MkDir()
+	var downloads int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&downloads, 1)
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write([]byte(pngSignature + "content"))
+	}))
+	defer server.Close()
+	cacheDirectory := c.MkDir()
+
+	instance := newAssetClient(newCacheTransport(http.DefaultTransport, cacheDirectory, false))
+	asset, err := instance.download(server.URL + "/avatar")
+	c.Assert(err, IsNil)
+	entries, err := os.ReadDir(cacheDirectory)
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 0)
+	c.Assert(instance.collectGarbage(organization{
+		Members: members{{ImageAsset: asset}},
+	}, 0), IsNil)
+
+	// Offline the assets which are known by the manifest are used.
+	offline := newAssetClient(newCacheTransport(http.DefaultTransport, cacheDirectory, true))
+	offline.offline = true
+	actual, err := offline.download(server.URL + "/avatar")
+	c.Assert(err, IsNil)
+	c.Assert(actual, Equals, asset)
+	_, err = offline.download(server.URL + "/other")
+	c.Assert(err, ErrorMatches, "'.+/other' was not downloaded before, but running offline")
+	c.Assert(atomic.LoadInt32(&downloads), Equals, int32(1))
+}
+
+func (s *assetClientSuite) TestDownloadsSameUrlOnlyOnce(c *C) {
+	*assetsFolder = c.MkDir()
+	var requests int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		<-release
+		_, _ = w.Write([]byte(pngSignature + "content"))
+	}))
+	defer server.Close()
+	instance := newAssetClient(http.DefaultTransport)
+
+	const callers = 20
+	results := make([]string, callers)
+	errs := make([]error, callers)
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = instance.download(server.URL + "/avatar")
+		}()
+	}
+	for atomic.LoadInt32(&requests) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	// Give all callers the chance to join the running download.
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	c.Assert(atomic.LoadInt32(&requests), Equals, int32(1))
+	for i := 0; i < callers; i++ {
+		c.Assert(errs[i], IsNil)
+		c.Assert(results[i], Equals, results[0])
+	}
+	c.Assert(results[0], Matches, "[0-9a-f]{64}\\.png")
+}
+
+func (s *assetClientSuite) TestDownloadsDifferentUrlsInParallel(c *C) {
+	*assetsFolder = c.MkDir()
+	const urls = 3
+	var running, maximum int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			if m := atomic.LoadInt32(&maximum); current <= m || atomic.CompareAndSwapInt32(&maximum, m, current) {
+				break
+			}
+		}
+		// Only returns early if all downloads run at the same time.
+		deadline := time.Now().Add(2 * time.Second)
+		for atomic.LoadInt32(&maximum) < urls && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		_, _ = w.Write([]byte(pngSignature + r.URL.Path))
+	}))
+	defer server.Close()
+	instance := newAssetClient(http.DefaultTransport)
+
+	var wg sync.WaitGroup
+	for i := 0; i < urls; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := instance.download(fmt.Sprintf("%s/avatar%d", server.URL, i))
+			c.Check(err, IsNil)
+		}()
+	}
+	wg.Wait()
+
+	c.Assert(atomic.LoadInt32(&maximum), Equals, int32(urls))
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	log "github.com/echocat/slf4g"
+)
+
+var (
+	assetsGcGrace = flag.Duration("gc-grace", 7*24*time.Hour, "Assets which are no longer referenced are only removed if they were not seen for at least this duration.")
+)
+
+// assetManifestFile is stored inside the assets folder.
+const assetManifestFile = ".manifest.json"
+
+// assetManifest records which source (URL or local file) was stored as which
+// asset. It allows conditional downloads and tells the garbage collection
+// when an asset was seen the last time.
+type assetManifest struct {
+	Entries map[string]assetManifestEntry `json:"entries"`
+}
+
+type assetManifestEntry struct {
+	Asset       string    `json:"asset"`
+	Hash        string    `json:"hash"`
+	ContentType string    `json:"contentType,omitempty"`
+	ETag        string    `json:"etag,omitempty"`
+	LastSeen    time.Time `json:"lastSeen"`
+}
+
+func loadAssetManifest(file string) (*assetManifest, error) {
+	b, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return &assetManifest{Entries: map[string]assetManifestEntry{}}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read asset manifest '%s': %w", file, err)
+	}
+	var result assetManifest
+	if err := json.Unmarshal(b, &result); err != nil {
+		return nil, fmt.Errorf("cannot decode asset manifest '%s': %w", file, err)
+	}
+	if result.Entries == nil {
+		result.Entries = map[string]assetManifestEntry{}
+	}
+	return &result, nil
+}
+
+func (instance assetManifest) save(file string) error {
+	b, err := json.MarshalIndent(instance, "", "  ")
+	if err != nil {
+		return fmt.Errorf("cannot encode asset manifest '%s': %w", file, err)
+	}
+	tmp := file + "~"
+	if err := os.WriteFile(tmp, b, 0644); err != nil {
+		return fmt.Errorf("cannot write asset manifest '%s': %w", file, err)
+	}
+	if err := os.Rename(tmp, file); err != nil {
+		return fmt.Errorf("cannot write asset manifest '%s': %w", file, err)
+	}
+	return nil
+}
+
+// loadedManifest has to be called while holding the manifestMutex. A broken
+// manifest is ignored; it only results in assets being downloaded again.
+func (instance *assetClient) loadedManifest() *assetManifest {
+	if instance.manifest == nil {
+		file := filepath.Join(*assetsFolder, assetManifestFile)
+		manifest, err := loadAssetManifest(file)
+		if err != nil {
+			log.WithError(err).
+				Warn("Cannot load asset manifest; it will be ignored.")
+			manifest = &assetManifest{Entries: map[string]assetManifestEntry{}}
+		}
+		instance.manifest = manifest
+	}
+	return instance.manifest
+}
+
+func (instance *assetClient) manifestEntry(sourceRef string) (assetManifestEntry, bool) {
+	instance.manifestMutex.Lock()
+	defer instance.manifestMutex.Unlock()
+
+	result, ok := instance.loadedManifest().Entries[sourceRef]
+	return result, ok
+}
+
+// remember records that the given source is stored as the given asset and
+// was seen right now.
+func (instance *assetClient) remember(sourceRef string, entry assetManifestEntry) {
+	instance.manifestMutex.Lock()
+	defer instance.manifestMutex.Unlock()
+
+	entry.LastSeen = time.Now()
+	instance.loadedManifest().Entries[sourceRef] = entry
+}
+
+// referencedAssets returns all assets used by this organization.
+func (instance organization) referencedAssets() map[string]bool {
+	result := map[string]bool{}
+	for _, project := range instance.Projects {
+		if project.ImageAsset != nil {
+			result[*project.ImageAsset] = true
+		}
+		for _, variant := range project.ImageVariants {
+			result[variant.Asset] = true
+		}
+	}
+	for _, member := range instance.Members {
+		result[member.ImageAsset] = true
+		for _, variant := range member.ImageVariants {
+			result[variant.Asset] = true
+		}
+		for _, account := range member.Accounts {
+			result[account.ImageAsset] = true
+		}
+	}
+	delete(result, "")
+	return result
+}
+
+// collectGarbage removes all files of the assets folder which are not
+// referenced by the given organization and were not seen within the given
+// grace period. It should only be called after the organization was saved,
+// so an interrupted run never leaves the site without its images. The
+// manifest is saved afterward.
+func (instance *assetClient) collectGarbage(org organization, grace time.Duration) error {
+	instance.manifestMutex.Lock()
+	defer instance.manifestMutex.Unlock()
+
+	manifest := instance.loadedManifest()
+	referenced := org.referencedAssets()
+	now := time.Now()
+
+	lastSeen := map[string]time.Time{}
+	for sourceRef, entry := range manifest.Entries {
+		if referenced[entry.Asset] {
+			entry.LastSeen = now
+			manifest.Entries[sourceRef] = entry
+		}
+		if entry.LastSeen.After(lastSeen[entry.Asset]) {
+			lastSeen[entry.Asset] = entry.LastSeen
+		}
+	}
+
+	entries, err := os.ReadDir(*assetsFolder)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("cannot list assets folder '%s': %w", *assetsFolder, err)
+	}
+	var removed int
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || name == assetManifestFile || referenced[name] {
+			continue
+		}
+		seen, ok := lastSeen[name]
+		if !ok {
+			// Not known by the manifest, like left over temporary files.
+			info, err := entry.Info()
+			if err != nil {
+				return fmt.Errorf("cannot inspect asset '%s': %w", name, err)
+			}
+			seen = info.ModTime()
+		}
+		if now.Sub(seen) < grace {
+			continue
+		}
+		if err := os.Remove(filepath.Join(*assetsFolder, name)); err != nil {
+			return fmt.Errorf("cannot remove unreferenced asset '%s': %w", name, err)
+		}
+		removed++
+	}
+
+	for sourceRef, entry := range manifest.Entries {
+		if !instance.exists(entry.Asset) {
+			delete(manifest.Entries, sourceRef)
+		}
+	}
+
+	log.With("referenced", len(referenced)).
+		With("removed", removed).
+		Info("Unreferenced assets removed.")
+
+	return manifest.save(filepath.Join(*assetsFolder, assetManifestFile))
+}
@@ -0,0 +1,434 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	bitbucketEntriesPerPage         = flag.Int("bitbucket-entriesPerPage", 50, "")
+	bitbucketMaximumNumberOfEntries = flag.Int("bitbucket-maximumNumberOfEntries", -1, "")
+	bitbucketAccessToken            = flag.String("bitbucketAccessToken", "", "Bitbucket accessToken to access the API.")
+)
+
+func init() {
+	clientFactories["bitbucket"] = func(assetClient *assetClient, transport http.RoundTripper, source sourceConfiguration) (client, error) {
+		if source.BaseUrl == "" {
+			source.BaseUrl = "https://api.bitbucket.org/2.0"
+		}
+		return newBitbucketClient(assetClient, transport, source, false), nil
+	}
+	clientFactories["bitbucket-server"] = func(assetClient *assetClient, transport http.RoundTripper, source sourceConfiguration) (client, error) {
+		if source.BaseUrl == "" {
+			return nil, fmt.Errorf("source of type '%s' requires a baseUrl", source.Type)
+		}
+		return newBitbucketClient(assetClient, transport, source, true), nil
+	}
+}
+
+// bitbucketClient retrieves the public repositories and members of either a
+// Bitbucket Cloud workspace (REST API 2.0) or a Bitbucket Server project
+// (REST API 1.0). For Bitbucket Server the organization is the project key
+// and the baseUrl the root of the instance (without "/rest/api/1.0").
+type bitbucketClient struct {
+	organization           string
+	baseUrl                string
+	server                 bool
+	accessToken            string
+	entriesPerPage         int
+	maximumNumberOfEntries int
+	assetClient            *assetClient
+	transport              http.RoundTripper
+}
+
+type bitbucketClientRetrieveTask struct {
+	*bitbucketClient
+
+	client *restClient
+	ctx    context.Context
+}
+
+// bitbucketPage covers both pagination schemes: Cloud returns the absolute
+// URL of the next page in "next", Server returns "isLastPage" and the
+// "nextPageStart" offset.
+type bitbucketPage struct {
+	Values        json.RawMessage `json:"values"`
+	Next          string          `json:"next"`
+	IsLastPage    bool            `json:"isLastPage"`
+	NextPageStart int             `json:"nextPageStart"`
+}
+
+type bitbucketLink struct {
+	Name string `json:"name"`
+	Href string `json:"href"`
+}
+
+type bitbucketLinks struct {
+	// Html, Avatar and Self are single objects on Cloud, while Self is a
+	// list on Server.
+	Html   bitbucketLink   `json:"html"`
+	Avatar bitbucketLink   `json:"avatar"`
+	Clone  []bitbucketLink `json:"clone"`
+	Self   json.RawMessage `json:"self"`
+}
+
+func (instance bitbucketLinks) clone(names ...string) string {
+	for _, name := range names {
+		for _, candidate := range instance.Clone {
+			if candidate.Name == name {
+				return candidate.Href
+			}
+		}
+	}
+	return ""
+}
+
+func (instance bitbucketLinks) self() string {
+	var list []bitbucketLink
+	if err := json.Unmarshal(instance.Self, &list); err == nil && len(list) > 0 {
+		return list[0].Href
+	}
+	var single bitbucketLink
+	if err := json.Unmarshal(instance.Self, &single); err == nil {
+		return single.Href
+	}
+	return ""
+}
+
+type bitbucketRepository struct {
+	Name        string         `json:"name"`
+	Slug        string         `json:"slug"`
+	FullName    string         `json:"full_name"`
+	Description string         `json:"description"`
+	Language    string         `json:"language"`
+	Website     string         `json:"website"`
+	IsPrivate   bool           `json:"is_private"`
+	Public      bool           `json:"public"`
+	Archived    bool           `json:"archived"`
+	HasIssues   bool           `json:"has_issues"`
+	HasWiki     bool           `json:"has_wiki"`
+	CreatedOn   *time.Time     `json:"created_on"`
+	UpdatedOn   *time.Time     `json:"updated_on"`
+	Links       bitbucketLinks `json:"links"`
+	MainBranch  *struct {
+		Name string `json:"name"`
+	} `json:"mainbranch"`
+	Project *struct {
+		Key string `json:"key"`
+	} `json:"project"`
+	// Parent is the repository this one was forked from (Cloud).
+	Parent *struct {
+		FullName string `json:"full_name"`
+	} `json:"parent"`
+	// Origin is the repository this one was forked from (Server).
+	Origin *struct {
+		Slug string `json:"slug"`
+	} `json:"origin"`
+}
+
+type bitbucketUser struct {
+	// Cloud
+	DisplayName string         `json:"display_name"`
+	Nickname    string         `json:"nickname"`
+	CreatedOn   *time.Time     `json:"created_on"`
+	Links       bitbucketLinks `json:"links"`
+	// Server
+	Name            string `json:"name"`
+	Slug            string `json:"slug"`
+	ServerName      string `json:"displayName"`
+	EmailAddress    string `json:"emailAddress"`
+	ServerAvatarUrl string `json:"avatarUrl"`
+}
+
+type bitbucketMembership struct {
+	User bitbucketUser `json:"user"`
+}
+
+func newBitbucketClient(assetClient *assetClient, transport http.RoundTripper, source sourceConfiguration, server bool) *bitbucketClient {
+	return &bitbucketClient{
+		organization:           source.Organization,
+		baseUrl:                strings.TrimSuffix(source.BaseUrl, "/"),
+		server:                 server,
+		accessToken:            source.accessToken(*bitbucketAccessToken),
+		entriesPerPage:         source.entriesPerPage(*bitbucketEntriesPerPage),
+		maximumNumberOfEntries: source.maximumNumberOfEntries(*bitbucketMaximumNumberOfEntries),
+		assetClient:            assetClient,
+		transport:              transport,
+	}
+}
+
+func (instance *bitbucketClient) retrieveOrganization() (organization, error) {
+	ctx := context.Background()
+
+	task := bitbucketClientRetrieveTask{
+		bitbucketClient: instance,
+		client:          instance.newClient(ctx),
+		ctx:             ctx,
+	}
+
+	return task.execute()
+}
+
+func (instance *bitbucketClientRetrieveTask) execute() (organization, error) {
+	if projects, err := instance.retrieveProjects(); err != nil {
+		return organization{}, err
+	} else if members, err := instance.retrieveMembers(); err != nil {
+		return organization{}, err
+	} else {
+		result := organization{
+			Projects: projects,
+			Members:  members,
+		}
+		result.align()
+		return result, nil
+	}
+}
+
+// page retrieves one page of path and decodes its values into target. next
+// is the reference to the page to retrieve as returned by a previous call,
+// empty for the first one. The returned reference is empty if there are no
+// more pages.
+func (instance *bitbucketClientRetrieveTask) page(path string, next string, query url.Values, target interface{}) (string, error) {
+	if query == nil {
+		query = url.Values{}
+	}
+	if instance.server {
+		query.Set("limit", strconv.Itoa(instance.entriesPerPage))
+		if next != "" {
+			query.Set("start", next)
+		}
+	} else {
+		query.Set("pagelen", strconv.Itoa(instance.entriesPerPage))
+		if next != "" {
+			// Cloud returns the complete URL including all query parameters.
+			path, query = next, nil
+		}
+	}
+
+	var page bitbucketPage
+	if _, err := instance.client.get(instance.ctx, path, query, &page); err != nil {
+		return "", err
+	}
+	if len(page.Values) > 0 {
+		if err := json.Unmarshal(page.Values, target); err != nil {
+			return "", fmt.Errorf("cannot decode values of '%s': %w", path, err)
+		}
+	}
+
+	if instance.server {
+		if page.IsLastPage {
+			return "", nil
+		}
+		return strconv.Itoa(page.NextPageStart), nil
+	}
+	return page.Next, nil
+}
+
+func (instance *bitbucketClientRetrieveTask) retrieveMembers() ([]member, error) {
+	var result []member
+	var path string
+	query := url.Values{}
+	if instance.server {
+		path = "projects/" + url.PathEscape(instance.organization) + "/permissions/users"
+		query.Set("avatarSize", "192")
+	} else {
+		path = "workspaces/" + url.PathEscape(instance.organization) + "/members"
+	}
+	for i, next := 1, ""; instance.maximumNumberOfEntries < 0 || i < instance.maximumNumberOfEntries; {
+		var memberships []bitbucketMembership
+		n, err := instance.page(path, next, query, &memberships)
+		if err != nil {
+			return result, fmt.Errorf("cannot search for members: %w", err)
+		}
+		for _, membership := range memberships {
+			if instance.maximumNumberOfEntries > 0 && i > instance.maximumNumberOfEntries {
+				break
+			}
+			if member, err := instance.userToMember(membership.User); err != nil {
+				return nil, fmt.Errorf("cannot get details for user '%s': %w", membership.User.Nickname+membership.User.Name, err)
+			} else {
+				result = append(result, member)
+			}
+			i++
+		}
+
+		if n == "" {
+			break
+		}
+		next = n
+	}
+	return result, nil
+}
+
+func (instance *bitbucketClientRetrieveTask) userToMember(user bitbucketUser) (member, error) {
+	name, fullname, avatarUrl := user.Nickname, user.DisplayName, user.Links.Avatar.Href
+	profile := user.Links.Html.Href
+	if instance.server {
+		name, fullname, avatarUrl = user.Slug, user.ServerName, user.ServerAvatarUrl
+		profile = user.Links.self()
+		if avatarUrl != "" && strings.HasPrefix(avatarUrl, "/") {
+			avatarUrl = instance.baseUrl + avatarUrl
+		}
+	}
+	if len(fullname) == 0 {
+		fullname = name
+	}
+
+	imageAsset := ""
+	if avatarUrl != "" {
+		r, err := instance.assetClient.retrieve(memberAssetOwner(name), avatarUrl)
+		if err != nil {
+			return member{}, err
+		}
+		imageAsset = r
+	}
+
+	return member{
+		Type:        "user:bitbucket",
+		Fullname:    fullname,
+		Name:        name,
+		Email:       pNonEmptyString(user.EmailAddress),
+		ImageAsset:  imageAsset,
+		ProfileUrl:  profile,
+		HomepageUrl: pNonEmptyString(profile),
+		CreatedAt:   user.CreatedOn,
+	}, nil
+}
+
+func (instance *bitbucketClientRetrieveTask) retrieveProjects() ([]project, error) {
+	var result []project
+	var path string
+	query := url.Values{}
+	if instance.server {
+		path = "projects/" + url.PathEscape(instance.organization) + "/repos"
+	} else {
+		path = "repositories/" + url.PathEscape(instance.organization)
+		query.Set("q", "is_private=false")
+	}
+	for i, next := 1, ""; instance.maximumNumberOfEntries < 0 || i < instance.maximumNumberOfEntries; {
+		var repos []bitbucketRepository
+		n, err := instance.page(path, next, query, &repos)
+		if err != nil {
+			return result, fmt.Errorf("cannot search for repositories: %w", err)
+		}
+		for _, repo := range repos {
+			if instance.maximumNumberOfEntries > 0 && i > instance.maximumNumberOfEntries {
+				break
+			}
+			if instance.isPublic(repo) {
+				if project, err := instance.repoToProject(repo); err != nil {
+					return nil, fmt.Errorf("cannot get details of project '%s': %w", repo.Name, err)
+				} else {
+					result = append(result, project)
+				}
+				i++
+			}
+		}
+
+		if n == "" {
+			break
+		}
+		next = n
+	}
+	return result, nil
+}
+
+func (instance *bitbucketClientRetrieveTask) isPublic(repo bitbucketRepository) bool {
+	if instance.server {
+		return repo.Public
+	}
+	return !repo.IsPrivate
+}
+
+func (instance *bitbucketClientRetrieveTask) repoToProject(repo bitbucketRepository) (project, error) {
+	name := repo.Slug
+	if len(name) == 0 {
+		name = repo.Name
+	}
+	fullname := repo.FullName
+	profile := repo.Links.Html.Href
+	if instance.server {
+		if repo.Project != nil {
+			fullname = repo.Project.Key + "/" + name
+		}
+		profile = strings.TrimSuffix(repo.Links.self(), "/browse")
+	}
+	if len(fullname) == 0 {
+		fullname = name
+	}
+	homepage := repo.Website
+	if len(homepage) == 0 {
+		homepage = profile
+	}
+	issuesUrl := ""
+	if repo.HasIssues {
+		issuesUrl = profile + "/issues"
+	}
+	wikiUrl := ""
+	if repo.HasWiki {
+		wikiUrl = profile + "/wiki"
+	}
+	pullRequestsUrl := profile + "/pull-requests"
+	forksUrl := profile + "/forks"
+	createForkUrl := profile + "/fork"
+	if instance.server {
+		createForkUrl = profile + "?fork"
+	}
+
+	defaultBranch := ""
+	if repo.MainBranch != nil {
+		defaultBranch = repo.MainBranch.Name
+	}
+
+	imageAsset := ""
+	if avatarUrl := repo.Links.Avatar.Href; avatarUrl != "" && !instance.server {
+		r, err := instance.assetClient.retrieve(projectAssetOwner("bitbucket", fullname), avatarUrl)
+		if err != nil {
+			return project{}, err
+		}
+		imageAsset = r
+	}
+
+	return project{
+		Type:            "repository:git:bitbucket",
+		Origin:          "bitbucket",
+		Fullname:        fullname,
+		Name:            name,
+		Description:     pNonEmptyString(repo.Description),
+		DefaultBranch:   pNonEmptyString(defaultBranch),
+		Language:        pNonEmptyString(canonicalLanguage(repo.Language)),
+		HomepageUrl:     pNonEmptyString(homepage),
+		ImageAsset:      pNonEmptyString(imageAsset),
+		ProfileUrl:      profile,
+		HttpCloneUrl:    pNonEmptyString(repo.Links.clone("https", "http")),
+		SshCloneUrl:     pNonEmptyString(repo.Links.clone("ssh")),
+		IssuesUrl:       pNonEmptyString(issuesUrl),
+		WikiUrl:         pNonEmptyString(wikiUrl),
+		PullRequestsUrl: &pullRequestsUrl,
+		ForksUrl:        &forksUrl,
+		CreateForkUrl:   &createForkUrl,
+		CreatedAt:       repo.CreatedOn,
+		UpdatedAt:       repo.UpdatedOn,
+		Fork:            repo.Parent != nil || repo.Origin != nil,
+		Archived:        repo.Archived,
+	}, nil
+}
+
+func (instance *bitbucketClient) newClient(_ context.Context) *restClient {
+	headers := http.Header{}
+	if len(instance.accessToken) > 0 {
+		headers.Set("Authorization", "Bearer "+instance.accessToken)
+	}
+	baseUrl := instance.baseUrl
+	if instance.server {
+		baseUrl += "/rest/api/1.0"
+	}
+	return newRestClient(baseUrl, headers, instance.transport)
+}
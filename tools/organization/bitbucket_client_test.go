@@ -0,0 +1,131 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "gopkg.in/check.v1"
+)
+
+type bitbucketClientSuite struct {
+	server *httptest.Server
+}
+
+var _ = Suite(&bitbucketClientSuite{})
+
+func (s *bitbucketClientSuite) SetUpTest(c *C) {
+	*assetsFolder = c.MkDir()
+
+	mux := http.NewServeMux()
+	// Bitbucket Cloud 2.0
+	mux.HandleFunc("/2.0/repositories/acme", func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Query().Get("q"), Equals, "is_private=false")
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("page") == "2" {
+			_, _ = w.Write([]byte(`{"values": [
+				{"slug": "bar", "full_name": "acme/bar", "links": {"html": {"href": "https://bitbucket.org/acme/bar"}}}
+			]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"next": "` + s.server.URL + `/2.0/repositories/acme?q=is_private%3Dfalse&page=2", "values": [
+			{"slug": "foo", "full_name": "acme/foo", "language": "go", "has_issues": true, "mainbranch": {"name": "main"},
+			 "links": {"html": {"href": "https://bitbucket.org/acme/foo"}, "clone": [
+				{"name": "https", "href": "https://bitbucket.org/acme/foo.git"},
+				{"name": "ssh", "href": "git@bitbucket.org:acme/foo.git"}
+			 ]}}
+		]}`))
+	})
+	mux.HandleFunc("/2.0/workspaces/acme/members", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"values": [
+			{"user": {"nickname": "jdoe", "display_name": "John Doe", "links": {"html": {"href": "https://bitbucket.org/jdoe/"}}}}
+		]}`))
+	})
+	// Bitbucket Server 1.0
+	mux.HandleFunc("/rest/api/1.0/projects/ACME/repos", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("start") == "1" {
+			_, _ = w.Write([]byte(`{"isLastPage": true, "values": [
+				{"slug": "private", "public": false, "project": {"key": "ACME"}}
+			]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"isLastPage": false, "nextPageStart": 1, "values": [
+			{"slug": "foo", "name": "Foo", "public": true, "project": {"key": "ACME"},
+			 "links": {"self": [{"href": "https://git.example.org/projects/ACME/repos/foo/browse"}], "clone": [
+				{"name": "http", "href": "https://git.example.org/scm/acme/foo.git"},
+				{"name": "ssh", "href": "ssh://git@git.example.org:7999/acme/foo.git"}
+			 ]}}
+		]}`))
+	})
+	mux.HandleFunc("/rest/api/1.0/projects/ACME/permissions/users", func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Query().Get("avatarSize"), Equals, "192")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"isLastPage": true, "values": [
+			{"user": {"name": "jdoe", "slug": "jdoe", "displayName": "John Doe", "emailAddress": "jdoe@example.org",
+			 "links": {"self": [{"href": "https://git.example.org/users/jdoe"}]}}}
+		]}`))
+	})
+	s.server = httptest.NewServer(mux)
+}
+
+func (s *bitbucketClientSuite) TearDownTest(_ *C) {
+	s.server.Close()
+}
+
+func (s *bitbucketClientSuite) TestRetrieveOrganizationFromCloud(c *C) {
+	instance := newBitbucketClient(newAssetClient(http.DefaultTransport), http.DefaultTransport, sourceConfiguration{
+		Organization: "acme",
+		BaseUrl:      s.server.URL + "/2.0",
+	}, false)
+
+	actual, err := instance.retrieveOrganization()
+	c.Assert(err, IsNil)
+
+	c.Assert(actual.Projects, HasLen, 2)
+	byName := map[string]project{}
+	for _, p := range actual.Projects {
+		byName[p.Name] = p
+	}
+	foo := byName["foo"]
+	c.Assert(foo.Type, Equals, "repository:git:bitbucket")
+	c.Assert(foo.Fullname, Equals, "acme/foo")
+	c.Assert(*foo.Language, Equals, "Golang")
+	c.Assert(*foo.DefaultBranch, Equals, "main")
+	c.Assert(*foo.HttpCloneUrl, Equals, "https://bitbucket.org/acme/foo.git")
+	c.Assert(*foo.SshCloneUrl, Equals, "git@bitbucket.org:acme/foo.git")
+	c.Assert(*foo.IssuesUrl, Equals, "https://bitbucket.org/acme/foo/issues")
+	c.Assert(*foo.PullRequestsUrl, Equals, "https://bitbucket.org/acme/foo/pull-requests")
+	c.Assert(byName["bar"].IssuesUrl, IsNil)
+
+	c.Assert(actual.Members, HasLen, 1)
+	c.Assert(actual.Members[0].Type, Equals, "user:bitbucket")
+	c.Assert(actual.Members[0].Name, Equals, "jdoe")
+	c.Assert(actual.Members[0].Fullname, Equals, "John Doe")
+	c.Assert(actual.Members[0].ProfileUrl, Equals, "https://bitbucket.org/jdoe/")
+}
+
+func (s *bitbucketClientSuite) TestRetrieveOrganizationFromServer(c *C) {
+	instance := newBitbucketClient(newAssetClient(http.DefaultTransport), http.DefaultTransport, sourceConfiguration{
+		Organization: "ACME",
+		BaseUrl:      s.server.URL,
+	}, true)
+
+	actual, err := instance.retrieveOrganization()
+	c.Assert(err, IsNil)
+
+	c.Assert(actual.Projects, HasLen, 1)
+	foo := actual.Projects[0]
+	c.Assert(foo.Name, Equals, "foo")
+	c.Assert(foo.Fullname, Equals, "ACME/foo")
+	c.Assert(foo.ProfileUrl, Equals, "https://git.example.org/projects/ACME/repos/foo")
+	c.Assert(*foo.HttpCloneUrl, Equals, "https://git.example.org/scm/acme/foo.git")
+	c.Assert(*foo.SshCloneUrl, Equals, "ssh://git@git.example.org:7999/acme/foo.git")
+	c.Assert(*foo.PullRequestsUrl, Equals, "https://git.example.org/projects/ACME/repos/foo/pull-requests")
+	c.Assert(foo.IssuesUrl, IsNil)
+
+	c.Assert(actual.Members, HasLen, 1)
+	c.Assert(actual.Members[0].Name, Equals, "jdoe")
+	c.Assert(*actual.Members[0].Email, Equals, "jdoe@example.org")
+	c.Assert(actual.Members[0].ProfileUrl, Equals, "https://git.example.org/users/jdoe")
+}
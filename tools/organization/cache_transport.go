@@ -0,0 +1,301 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	log "github.com/echocat/slf4g"
+)
+
+var (
+	cacheDirectory  = flag.String("cache", "", "Directory of the persistent HTTP cache. If set, responses are stored with their ETag/Last-Modified and revalidated with conditional requests on the next run.")
+	offline         = flag.Bool("offline", false, "Serve all requests from the HTTP cache without accessing the network. Requires --cache.")
+	cacheMaximumAge = flag.Duration("cache-maximumAge", 30*24*time.Hour, "Entries of the HTTP cache which were neither stored nor revalidated within this duration are removed at the start of a run. 0 keeps them forever.")
+)
+
+// cacheTransport is a persistent HTTP cache. Responses of GET requests with
+// an ETag or Last-Modified header are stored and revalidated with conditional
+// requests; a 304 is answered with the stored response. Responses of other
+// successful requests (like GraphQL queries) are stored, too, but are only
+// served in offline mode. Assets are not cached by it, because the
+// assetManifest already records their ETags.
+type cacheTransport struct {
+	delegate  http.RoundTripper
+	directory string
+	offline   bool
+}
+
+type cacheEntry struct {
+	Method   string      `json:"method"`
+	Url      string      `json:"url"`
+	Status   int         `json:"status"`
+	Header   http.Header `json:"header"`
+	Body     []byte      `json:"body"`
+	StoredAt time.Time   `json:"storedAt"`
+}
+
+// cacheStatusHeader is added to all responses which are served from cache.
+const cacheStatusHeader = "X-Organization-Cache"
+
+func newCacheTransport(delegate http.RoundTripper, directory string, offline bool) *cacheTransport {
+	return &cacheTransport{
+		delegate:  delegate,
+		directory: directory,
+		offline:   offline,
+	}
+}
+
+// withoutCache returns the transport which the given one caches, if it is a
+// cacheTransport.
+func withoutCache(transport http.RoundTripper) http.RoundTripper {
+	if cached, ok := transport.(*cacheTransport); ok {
+		return cached.delegate
+	}
+	return transport
+}
+
+func (instance *cacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	key, body, err := instance.keyOf(req)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		// The body was consumed to build the key.
+		req = req.Clone(req.Context())
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+	}
+
+	entry, err := instance.load(key)
+	if err != nil {
+		log.WithError(err).
+			With("url", req.URL.Redacted()).
+			Warn("Cannot read cache entry; it will be ignored.")
+		entry = nil
+	}
+
+	if instance.offline {
+		if entry == nil {
+			return nil, fmt.Errorf("%s %s is not cached, but running offline", req.Method, req.URL.Redacted())
+		}
+		return entry.toResponse(req, "offline"), nil
+	}
+
+	conditional := req.Method == http.MethodGet && entry != nil &&
+		req.Header.Get("If-None-Match") == "" && req.Header.Get("If-Modified-Since") == ""
+	if conditional {
+		req = req.Clone(req.Context())
+		if etag := entry.Header.Get("ETag"); etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		if lastModified := entry.Header.Get("Last-Modified"); lastModified != "" {
+			req.Header.Set("If-Modified-Since", lastModified)
+		}
+	}
+
+	resp, err := instance.delegate.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if conditional && resp.StatusCode == http.StatusNotModified {
+		_ = resp.Body.Close()
+		// Headers like the rate limit ones are taken from the fresh response.
+		for name, values := range resp.Header {
+			entry.Header[name] = values
+		}
+		if err := instance.store(key, *entry); err != nil {
+			log.WithError(err).
+				With("url", req.URL.Redacted()).
+				Warn("Cannot update cache entry.")
+		}
+		return entry.toResponse(req, "revalidated"), nil
+	}
+
+	if !instance.isStorable(req, resp) {
+		return resp, nil
+	}
+	return instance.storeResponse(key, req, resp)
+}
+
+func (instance *cacheTransport) isStorable(req *http.Request, resp *http.Response) bool {
+	if resp.StatusCode != http.StatusOK {
+		return false
+	}
+	if req.Method != http.MethodGet {
+		// Only used in offline mode.
+		return true
+	}
+	return resp.Header.Get("ETag") != "" || resp.Header.Get("Last-Modified") != ""
+}
+
+func (instance *cacheTransport) storeResponse(key string, req *http.Request, resp *http.Response) (*http.Response, error) {
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("cannot read response of %s %s: %w", req.Method, req.URL.Redacted(), err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	if err := instance.store(key, cacheEntry{
+		Method:   req.Method,
+		Url:      req.URL.Redacted(),
+		Status:   resp.StatusCode,
+		Header:   resp.Header.Clone(),
+		Body:     body,
+		StoredAt: time.Now(),
+	}); err != nil {
+		log.WithError(err).
+			With("url", req.URL.Redacted()).
+			Warn("Cannot store cache entry.")
+	}
+	return resp, nil
+}
+
+// cacheCredentialHeaders carry the credentials of the sources; GitLab uses
+// its own header instead of Authorization.
+var cacheCredentialHeaders = []string{"Authorization", "Private-Token"}
+
+// keyOf returns the cache key of the request. It is based on the method, the
+// URL, the Accept header, a hash of the credentials and the body, so sources
+// with different credentials never share entries. If the request has a body
+// it is returned, too, because it was consumed.
+func (instance *cacheTransport) keyOf(req *http.Request) (key string, body []byte, err error) {
+	hash := sha256.New()
+	_, _ = fmt.Fprintf(hash, "%s %s\n%s\n", req.Method, req.URL.String(), req.Header.Get("Accept"))
+	for _, name := range cacheCredentialHeaders {
+		if value := req.Header.Get(name); value != "" {
+			_, _ = fmt.Fprintf(hash, "%s: %x\n", name, sha256.Sum256([]byte(value)))
+		}
+	}
+	if req.Body != nil && req.Body != http.NoBody {
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return "", nil, fmt.Errorf("cannot read body of %s %s: %w", req.Method, req.URL.Redacted(), err)
+		}
+		_ = req.Body.Close()
+		_, _ = hash.Write(body)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), body, nil
+}
+
+func (instance *cacheTransport) fileOf(key string) string {
+	return filepath.Join(instance.directory, key[:2], key+".json")
+}
+
+func (instance *cacheTransport) load(key string) (*cacheEntry, error) {
+	b, err := os.ReadFile(instance.fileOf(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var result cacheEntry
+	if err := json.Unmarshal(b, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (instance *cacheTransport) store(key string, entry cacheEntry) error {
+	file := instance.fileOf(key)
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	// Write to a temporary file first, so concurrent runs never see partial
+	// entries.
+	tmp, err := os.CreateTemp(filepath.Dir(file), "~*.json")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+	if _, err := tmp.Write(b); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
+
+func (instance cacheEntry) toResponse(req *http.Request, status string) *http.Response {
+	header := instance.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	header.Set(cacheStatusHeader, status)
+	header.Set("Content-Length", strconv.Itoa(len(instance.Body)))
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", instance.Status, http.StatusText(instance.Status)),
+		StatusCode:    instance.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(instance.Body)),
+		ContentLength: int64(len(instance.Body)),
+		Request:       req,
+	}
+}
+
+// evictCache removes all entries of the cache in the given directory which
+// were neither stored nor revalidated within maximumAge, as every store
+// rewrites the file of an entry.
+func evictCache(directory string, maximumAge time.Duration) error {
+	if maximumAge <= 0 {
+		return nil
+	}
+	threshold := time.Now().Add(-maximumAge)
+	var evicted int
+	err := filepath.WalkDir(directory, func(path string, entry os.DirEntry, err error) error {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		info, err := entry.Info()
+		if errors.Is(err, os.ErrNotExist) {
+			// Removed by a concurrent run.
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.ModTime().After(threshold) {
+			return nil
+		}
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		evicted++
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("cannot evict entries of cache '%s': %w", directory, err)
+	}
+	log.With("directory", directory).
+		With("evicted", evicted).
+		Debug("Evicted outdated cache entries.")
+	return nil
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type cacheTransportSuite struct{}
+
+var _ = Suite(&cacheTransportSuite{})
+
+func (s *cacheTransportSuite) get(c *C, client *http.Client, url string) (*http.Response, string) {
+	resp, err := client.Get(url)
+	c.Assert(err, IsNil)
+	defer func() { _ = resp.Body.Close() }()
+	b, err := io.ReadAll(resp.Body)
+	c.Assert(err, IsNil)
+	return resp, string(b)
+}
+
+func (s *cacheTransportSuite) TestRevalidatesWithETag(c *C) {
+	var requests, notModified int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		c.Check(r.Header.Get("Authorization"), Equals, "token secret")
+		w.Header().Set("X-RateLimit-Remaining", "4999")
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			atomic.AddInt32(&notModified, 1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"name":"foo"}`))
+	}))
+	defer server.Close()
+	directory := c.MkDir()
+	client := &http.Client{Transport: &authorizingTransport{newCacheTransport(http.DefaultTransport, directory, false)}}
+
+	resp, body := s.get(c, client, server.URL+"/repos")
+	c.Assert(resp.StatusCode, Equals, http.StatusOK)
+	c.Assert(resp.Header.Get(cacheStatusHeader), Equals, "")
+	c.Assert(body, Equals, `{"name":"foo"}`)
+
+	resp, body = s.get(c, client, server.URL+"/repos")
+	c.Assert(resp.StatusCode, Equals, http.StatusOK)
+	c.Assert(resp.Header.Get(cacheStatusHeader), Equals, "revalidated")
+	c.Assert(resp.Header.Get("Content-Type"), Equals, "application/json")
+	c.Assert(body, Equals, `{"name":"foo"}`)
+	c.Assert(atomic.LoadInt32(&requests), Equals, int32(2))
+	c.Assert(atomic.LoadInt32(&notModified), Equals, int32(1))
+
+	server.Close()
+	offlineClient := &http.Client{Transport: &authorizingTransport{newCacheTransport(http.DefaultTransport, directory, true)}}
+	resp, body = s.get(c, offlineClient, server.URL+"/repos")
+	c.Assert(resp.Header.Get(cacheStatusHeader), Equals, "offline")
+	c.Assert(body, Equals, `{"name":"foo"}`)
+
+	_, err := offlineClient.Get(server.URL + "/other")
+	c.Assert(err, ErrorMatches, ".*is not cached, but running offline.*")
+
+	// Requests with other or without credentials do not share the entries.
+	anonymousClient := &http.Client{Transport: newCacheTransport(http.DefaultTransport, directory, true)}
+	_, err = anonymousClient.Get(server.URL + "/repos")
+	c.Assert(err, ErrorMatches, ".*is not cached, but running offline.*")
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/repos", nil)
+	c.Assert(err, IsNil)
+	req.Header.Set("Authorization", "token other")
+	_, err = anonymousClient.Do(req)
+	c.Assert(err, ErrorMatches, ".*is not cached, but running offline.*")
+
+	// Only a hash of the credentials is stored.
+	err = filepath.WalkDir(directory, func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			b, rErr := os.ReadFile(path)
+			c.Assert(rErr, IsNil)
+			c.Check(strings.Contains(string(b), "secret"), Equals, false)
+		}
+		return err
+	})
+	c.Assert(err, IsNil)
+}
+
+func (s *cacheTransportSuite) TestDoesNotStoreResponsesWithoutValidators(c *C) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		c.Check(r.Header.Get("If-None-Match"), Equals, "")
+		_, _ = w.Write([]byte("plain"))
+	}))
+	defer server.Close()
+	client := &http.Client{Transport: newCacheTransport(http.DefaultTransport, c.MkDir(), false)}
+
+	_, body := s.get(c, client, server.URL)
+	c.Assert(body, Equals, "plain")
+	resp, body := s.get(c, client, server.URL)
+	c.Assert(body, Equals, "plain")
+	c.Assert(resp.Header.Get(cacheStatusHeader), Equals, "")
+	c.Assert(atomic.LoadInt32(&requests), Equals, int32(2))
+}
+
+func (s *cacheTransportSuite) TestStoresQueriesForOfflineMode(c *C) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		_, _ = w.Write([]byte("answer to " + string(b)))
+	}))
+	directory := c.MkDir()
+	client := &http.Client{Transport: newCacheTransport(http.DefaultTransport, directory, false)}
+
+	for _, query := range []string{"a", "b"} {
+		resp, err := client.Post(server.URL+"/graphql", "application/json", strings.NewReader(query))
+		c.Assert(err, IsNil)
+		_ = resp.Body.Close()
+	}
+	server.Close()
+
+	offlineClient := &http.Client{Transport: newCacheTransport(http.DefaultTransport, directory, true)}
+	resp, err := offlineClient.Post(server.URL+"/graphql", "application/json", strings.NewReader("b"))
+	c.Assert(err, IsNil)
+	b, err := io.ReadAll(resp.Body)
+	c.Assert(err, IsNil)
+	c.Assert(string(b), Equals, "answer to b")
+}
+
+type authorizingTransport struct {
+	delegate http.RoundTripper
+}
+
+func (instance *authorizingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "token secret")
+	return instance.delegate.RoundTrip(req)
+}
+
+func (s *cacheTransportSuite) TestEvictsOutdatedEntries(c *C) {
+	directory := c.MkDir()
+	outdated := filepath.Join(directory, "ab", "abc.json")
+	recent := filepath.Join(directory, "cd", "cde.json")
+	for _, file := range []string{outdated, recent} {
+		c.Assert(os.MkdirAll(filepath.Dir(file), 0755), IsNil)
+		c.Assert(os.WriteFile(file, []byte("{}"), 0644), IsNil)
+	}
+	old := time.Now().Add(-48 * time.Hour)
+	c.Assert(os.Chtimes(outdated, old, old), IsNil)
+
+	c.Assert(evictCache(directory, 0), IsNil)
+	c.Assert(evictCache(filepath.Join(directory, "does-not-exist"), time.Hour), IsNil)
+	_, err := os.Stat(outdated)
+	c.Assert(err, IsNil)
+
+	c.Assert(evictCache(directory, 24*time.Hour), IsNil)
+	_, err = os.Stat(outdated)
+	c.Assert(os.IsNotExist(err), Equals, true)
+	_, err = os.Stat(recent)
+	c.Assert(err, IsNil)
+}
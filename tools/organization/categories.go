@@ -0,0 +1,110 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	log "github.com/echocat/slf4g"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	categoriesFile = flag.String("categories", "", "YAML file which assigns categories to projects by their topics or names, like the stocks.yml of the site.")
+)
+
+// categories assign a category to every project without one. All topics
+// assignments are tried before any pattern, so a topic wins over a name.
+type categories struct {
+	Assignments []categoryAssignment `yaml:"assignments"`
+
+	file string
+}
+
+type categoryAssignment struct {
+	// Topics matches like projectRule.Topics.
+	Topics []string `yaml:"topics"`
+	// Pattern is a regular expression which is matched against the fullname
+	// of the project.
+	Pattern string `yaml:"pattern"`
+	// Class is the resulting category.
+	Class string `yaml:"class"`
+
+	pattern *regexp.Regexp
+}
+
+// loadCategories returns nil if no categories file was configured.
+func loadCategories() (*categories, error) {
+	if *categoriesFile == "" {
+		return nil, nil
+	}
+	return loadCategoriesFrom(*categoriesFile)
+}
+
+func loadCategoriesFrom(file string) (*categories, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read categories '%s': %w", file, err)
+	}
+
+	result := categories{file: file}
+	if err := yaml.Unmarshal(b, &result); err != nil {
+		return nil, fmt.Errorf("cannot parse categories '%s': %w", file, err)
+	}
+	for i, assignment := range result.Assignments {
+		if assignment.Class == "" {
+			return nil, fmt.Errorf("assignments[%d] of categories '%s' has no class", i, file)
+		}
+		if len(assignment.Topics) == 0 && assignment.Pattern == "" {
+			return nil, fmt.Errorf("assignments[%d] of categories '%s' has neither topics nor a pattern", i, file)
+		}
+		if assignment.Pattern != "" {
+			if result.Assignments[i].pattern, err = regexp.Compile(assignment.Pattern); err != nil {
+				return nil, fmt.Errorf("assignments[%d] of categories '%s' has an illegal pattern: %w", i, file, err)
+			}
+		}
+	}
+	return &result, nil
+}
+
+// apply resolves the category of every project which does not have one yet,
+// for example by an override. Visible projects without any match are
+// reported, because the categories most likely need to be extended.
+func (instance categories) apply(to organization) organization {
+	result := to
+	result.Projects = append(projects{}, to.Projects...)
+
+	var unmatched []string
+	for i, candidate := range result.Projects {
+		if candidate.Category != nil {
+			continue
+		}
+		if class, ok := instance.resolve(candidate); ok {
+			result.Projects[i].Category = &class
+		} else if !candidate.Hidden {
+			unmatched = append(unmatched, candidate.Origin+":"+candidate.Fullname)
+		}
+	}
+	if len(unmatched) > 0 {
+		log.With("file", instance.file).
+			With("projects", strings.Join(unmatched, ", ")).
+			Warn("Projects do not match any category; the categories should be extended.")
+	}
+	return result
+}
+
+func (instance categories) resolve(candidate project) (string, bool) {
+	for _, assignment := range instance.Assignments {
+		if candidate.hasAnyTopic(assignment.Topics) {
+			return assignment.Class, true
+		}
+	}
+	for _, assignment := range instance.Assignments {
+		if assignment.pattern != nil && assignment.pattern.MatchString(candidate.Fullname) {
+			return assignment.Class, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+)
+
+type categoriesSuite struct{}
+
+var _ = Suite(&categoriesSuite{})
+
+func (s *categoriesSuite) load(c *C, content string) (*categories, error) {
+	file := filepath.Join(c.MkDir(), "stocks.yml")
+	c.Assert(os.WriteFile(file, []byte(content), 0644), IsNil)
+	return loadCategoriesFrom(file)
+}
+
+func (s *categoriesSuite) TestApply(c *C) {
+	instance, err := s.load(c, `
+assignments:
+  - pattern: "redis"
+    class: redis
+  - topics: [prometheus-exporter]
+    pattern: "_exporter$"
+    class: prometheus
+  - topics: [Docker]
+    class: docker
+`)
+	c.Assert(err, IsNil)
+
+	actual := instance.apply(organization{Projects: projects{
+		// Topics are matched before patterns.
+		{Origin: "github", Fullname: "echocat/redis_exporter", Topics: []string{"prometheus-exporter"}},
+		{Origin: "github", Fullname: "echocat/redis-image", Topics: []string{"docker"}},
+		{Origin: "github", Fullname: "echocat/redis"},
+		{Origin: "github", Fullname: "echocat/foo_exporter"},
+		{Origin: "github", Fullname: "echocat/overridden", Category: pString("special")},
+		{Origin: "github", Fullname: "echocat/unknown"},
+	}})
+
+	c.Assert(*actual.Projects[0].Category, Equals, "prometheus")
+	c.Assert(*actual.Projects[1].Category, Equals, "docker")
+	c.Assert(*actual.Projects[2].Category, Equals, "redis")
+	c.Assert(*actual.Projects[3].Category, Equals, "prometheus")
+	c.Assert(*actual.Projects[4].Category, Equals, "special")
+	c.Assert(actual.Projects[5].Category, IsNil)
+}
+
+func (s *categoriesSuite) TestLoadRejectsIllegalAssignments(c *C) {
+	_, err := s.load(c, `
+assignments:
+  - class: foo
+`)
+	c.Assert(err, ErrorMatches, "assignments\\[0\\] of categories '.+' has neither topics nor a pattern")
+
+	_, err = s.load(c, `
+assignments:
+  - pattern: "("
+    class: foo
+`)
+	c.Assert(err, ErrorMatches, "assignments\\[0\\] of categories '.+' has an illegal pattern: .*")
+}
+
+func (s *categoriesSuite) TestSiteStocksAreValid(c *C) {
+	_, err := loadCategoriesFrom(filepath.Join("..", "..", "site", "data", "stocks.yml"))
+	c.Assert(err, IsNil)
+}
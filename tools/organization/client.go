@@ -1,5 +1,13 @@
 package main
 
+import "net/http"
+
 type client interface {
 	retrieveOrganization() (organization, error)
 }
+
+type clientFactory func(assetClient *assetClient, transport http.RoundTripper, source sourceConfiguration) (client, error)
+
+// clientFactories contains for every supported source type the factory
+// which creates the matching client. Every provider registers itself here.
+var clientFactories = map[string]clientFactory{}
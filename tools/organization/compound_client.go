@@ -1,23 +1,106 @@
 package main
 
-import log "github.com/echocat/slf4g"
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
 
-type compoundClient []client
+	log "github.com/echocat/slf4g"
+)
+
+type compoundClient struct {
+	delegates    []compoundClientDelegate
+	rules        rules
+	identities   identities
+	mirrors      mirrors
+	contributors contributors
+	assetClient  *assetClient
+	// goModuleProxy is used to cross-check the releases of Go projects; it
+	// is nil if disabled.
+	goModuleProxy *goModuleProxy
+	// previousFile is the output of the last run. If set, a failing source
+	// does not fail the whole retrieval; the last known data of this source
+	// is read from this file instead.
+	previousFile string
+}
+
+type compoundClientDelegate struct {
+	name       string
+	sourceType string
+	client     client
+}
 
 func (instance *compoundClient) retrieveOrganization() (organization, error) {
 
 	log.Info("Starting to retrieve the organization details...")
 
-	var result organization
-	for _, delegate := range *instance {
-		if org, err := delegate.retrieveOrganization(); err != nil {
+	// All sources are retrieved concurrently; the results are processed in
+	// the configured order to keep the output stable.
+	retrieved := make([]organization, len(instance.delegates))
+	errs := make([]error, len(instance.delegates))
+	var wg sync.WaitGroup
+	for i, delegate := range instance.delegates {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			retrieved[i], errs[i] = delegate.client.retrieveOrganization()
+		}()
+	}
+	wg.Wait()
+
+	if instance.previousFile == "" {
+		var failures []error
+		for i, err := range errs {
+			if err != nil {
+				failures = append(failures, fmt.Errorf("cannot retrieve source '%s': %w", instance.delegates[i].name, err))
+			}
+		}
+		if err := errors.Join(failures...); err != nil {
 			return organization{}, err
+		}
+	}
+
+	var result organization
+	var statuses []sourceStatus
+	var previous *organization
+	for i, delegate := range instance.delegates {
+		org, err := retrieved[i], errs[i]
+
+		var status sourceStatus
+		if err != nil {
+			log.WithError(err).
+				With("source", delegate.name).
+				Error("Cannot retrieve source; using its last known data instead.")
+			if previous == nil {
+				var pErr error
+				if previous, pErr = loadPreviousOrganization(instance.previousFile); pErr != nil {
+					return organization{}, pErr
+				}
+			}
+			org, status = previous.fallbackFor(delegate, err, instance.assetClient)
 		} else {
-			result = result.merge(org)
+			org.assignSource(delegate.name)
+			status = sourceStatus{
+				Name:        delegate.name,
+				Type:        delegate.sourceType,
+				State:       sourceStateFresh,
+				RetrievedAt: pTime(time.Now()),
+			}
 		}
+		statuses = append(statuses, status)
+		result = result.merge(org)
 	}
 
-	result = result.clean()
+	result.Projects = instance.mirrors.collapse(result.Projects)
+	result.Members = instance.identities.resolve(result.Members)
+	result = result.clean(instance.rules)
+	// Only for the remaining projects, because it might require API calls.
+	result.Projects = resolveLicenses(result.Projects)
+	result.Projects = resolveReleases(result.Projects, instance.goModuleProxy)
+	result.Projects = instance.contributors.resolve(result.Projects, instance.identities)
+	result.align()
+	result.Sources = statuses
 
 	log.Info("Starting to retrieve the organization details... DONE!")
 
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"sync"
+)
+
+var (
+	concurrency = flag.Int("concurrency", 8, "Maximum number of concurrent detail requests per source. All sources are retrieved concurrently.")
+)
+
+// mapConcurrently calls f for every input with at most concurrency calls at
+// the same time. The results have the same order as the inputs. If at least
+// one call fails, the errors of all failed calls are returned joined.
+func mapConcurrently[I any, O any](inputs []I, f func(I) (O, error)) ([]O, error) {
+	results := make([]O, len(inputs))
+	errs := make([]error, len(inputs))
+
+	workers := *concurrency
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(inputs) {
+		workers = len(inputs)
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i], errs[i] = f(inputs[i])
+			}
+		}()
+	}
+	for i := range inputs {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type concurrencySuite struct{}
+
+var _ = Suite(&concurrencySuite{})
+
+func (s *concurrencySuite) TestMapConcurrentlyKeepsOrderAndBounds(c *C) {
+	defer func(previous int) { *concurrency = previous }(*concurrency)
+	*concurrency = 3
+
+	var running, maximum int32
+	inputs := make([]int, 20)
+	for i := range inputs {
+		inputs[i] = i
+	}
+
+	actual, err := mapConcurrently(inputs, func(in int) (string, error) {
+		current := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			if old := atomic.LoadInt32(&maximum); current <= old || atomic.CompareAndSwapInt32(&maximum, old, current) {
+				break
+			}
+		}
+		// Later inputs finish first to ensure the order is not the one of completion.
+		time.Sleep(time.Duration(len(inputs)-in) * time.Millisecond)
+		return fmt.Sprint(in), nil
+	})
+
+	c.Assert(err, IsNil)
+	c.Assert(actual, HasLen, 20)
+	for i, v := range actual {
+		c.Assert(v, Equals, fmt.Sprint(i))
+	}
+	c.Assert(maximum <= 3, Equals, true)
+}
+
+func (s *concurrencySuite) TestMapConcurrentlyJoinsErrors(c *C) {
+	actual, err := mapConcurrently([]int{1, 2, 3, 4}, func(in int) (int, error) {
+		if in%2 == 0 {
+			return 0, fmt.Errorf("failed %d", in)
+		}
+		return in, nil
+	})
+
+	c.Assert(actual, IsNil)
+	c.Assert(err, ErrorMatches, "failed 2\nfailed 4")
+}
+
+func (s *concurrencySuite) TestCompoundClientJoinsErrorsOfAllSources(c *C) {
+	instance := &compoundClient{
+		delegates: []compoundClientDelegate{{
+			name:   "a",
+			client: clientFunc(func() (organization, error) { return organization{}, errors.New("first") }),
+		}, {
+			name:   "b",
+			client: clientFunc(func() (organization, error) { return organization{}, nil }),
+		}, {
+			name:   "c",
+			client: clientFunc(func() (organization, error) { return organization{}, errors.New("second") }),
+		}},
+	}
+
+	_, err := instance.retrieveOrganization()
+	c.Assert(err, ErrorMatches, "cannot retrieve source 'a': first\ncannot retrieve source 'c': second")
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	configFile = flag.String("config", "", "YAML or JSON file which describes the sources to retrieve the organization from. If absent the sources are derived from the other flags.")
+)
+
+type configuration struct {
+	Sources []sourceConfiguration `yaml:"sources"`
+	// Rules to decide which projects and members are kept. They are merged
+	// with the defaultRules: the configured project rules are evaluated
+	// first, followed by the default ones which exclude archived projects;
+	// the default member rules are only used if none are configured.
+	Rules *rules `yaml:"rules"`
+	// Identities link accounts of the same person at different providers.
+	Identities identities `yaml:"identities"`
+	// Mirrors configure how mirrored projects are detected and collapsed.
+	Mirrors mirrors `yaml:"mirrors"`
+	// Contributors configure how the contributors of projects are collected.
+	Contributors contributors `yaml:"contributors"`
+}
+
+type sourceConfiguration struct {
+	// Name identifies this source in the output, for example in the status
+	// of all sources. If empty it is derived from Type and Organization.
+	Name string `yaml:"name"`
+	// Type selects the provider, for example "github" or "gitlab".
+	Type string `yaml:"type"`
+	// Organization is the organization (GitHub) or group (GitLab) identifier.
+	Organization string `yaml:"organization"`
+	// BaseUrl of the API. If empty the public instance of the provider is used.
+	BaseUrl string `yaml:"baseUrl"`
+	// TrackerBaseUrl of the issue tracker if this is a separate service like
+	// todo.sr.ht. If empty it is derived from BaseUrl.
+	TrackerBaseUrl string `yaml:"trackerBaseUrl"`
+	// Api selects the API of providers which offer several ones, for example
+	// "rest" or "graphql" for GitHub. If empty the flag of the provider is used.
+	Api string `yaml:"api"`
+	// File to read the entries from; only used by sources like "static".
+	File string `yaml:"file"`
+	// TokenEnv is the name of the environment variable which contains the
+	// accessToken. If empty the accessToken flag of the provider is used.
+	TokenEnv               string `yaml:"tokenEnv"`
+	EntriesPerPage         int    `yaml:"entriesPerPage"`
+	MaximumNumberOfEntries *int   `yaml:"maximumNumberOfEntries"`
+	// Tls settings of this source in addition to the ones of the tls-* flags.
+	Tls *transportConfiguration `yaml:"tls"`
+}
+
+func loadConfiguration() (configuration, error) {
+	if *configFile == "" {
+		return defaultConfiguration(), nil
+	}
+	return loadConfigurationFrom(*configFile)
+}
+
+func loadConfigurationFrom(file string) (configuration, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return configuration{}, fmt.Errorf("cannot open configuration '%s': %w", file, err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	var result configuration
+	// YAML is a superset of JSON, so this will also handle JSON files.
+	if err := yaml.NewDecoder(f).Decode(&result); err != nil {
+		return configuration{}, fmt.Errorf("cannot parse configuration '%s': %w", file, err)
+	}
+	if len(result.Sources) == 0 {
+		return configuration{}, fmt.Errorf("configuration '%s' does not contain any source", file)
+	}
+	if result.Rules != nil {
+		if err := result.Rules.validate(); err != nil {
+			return configuration{}, fmt.Errorf("configuration '%s' contains illegal rules: %w", file, err)
+		}
+	}
+	if err := result.Identities.validate(); err != nil {
+		return configuration{}, fmt.Errorf("configuration '%s' contains illegal identities: %w", file, err)
+	}
+	if err := result.Mirrors.validate(); err != nil {
+		return configuration{}, fmt.Errorf("configuration '%s' contains illegal mirrors: %w", file, err)
+	}
+	if err := result.Contributors.validate(); err != nil {
+		return configuration{}, fmt.Errorf("configuration '%s' contains illegal contributors: %w", file, err)
+	}
+	names := map[string]int{}
+	for i, source := range result.Sources {
+		if other, ok := names[source.name()]; ok {
+			return configuration{}, fmt.Errorf("configuration '%s' contains at sources[%d] and sources[%d] the same name '%s'", file, other, i, source.name())
+		}
+		names[source.name()] = i
+		if _, ok := clientFactories[source.Type]; !ok {
+			return configuration{}, fmt.Errorf("configuration '%s' contains at sources[%d] the unknown type '%s'", file, i, source.Type)
+		}
+		if source.Organization == "" && source.File == "" {
+			return configuration{}, fmt.Errorf("configuration '%s' contains at sources[%d] neither an organization nor a file", file, i)
+		}
+		if source.Tls != nil {
+			if err := source.Tls.validate(); err != nil {
+				return configuration{}, fmt.Errorf("configuration '%s' contains at sources[%d] illegal tls settings: %w", file, i, err)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+func defaultConfiguration() configuration {
+	return configuration{
+		Sources: []sourceConfiguration{{
+			Type:         "github",
+			Organization: "echocat",
+		}, {
+			Type:         "gitlab",
+			Organization: "3460920",
+		}},
+	}
+}
+
+func (instance configuration) rules() rules {
+	if instance.Rules != nil {
+		return instance.Rules.withDefaults()
+	}
+	return defaultRules()
+}
+
+func (instance configuration) newClients(assetClient *assetClient) (*compoundClient, error) {
+	result := &compoundClient{
+		delegates:    make([]compoundClientDelegate, len(instance.Sources)),
+		rules:        instance.rules(),
+		identities:   instance.Identities,
+		mirrors:      instance.Mirrors,
+		contributors: instance.Contributors,
+		assetClient:  assetClient,
+	}
+	for i, source := range instance.Sources {
+		factory, ok := clientFactories[source.Type]
+		if !ok {
+			return nil, fmt.Errorf("unknown source type '%s'", source.Type)
+		}
+		transport, err := defaultTransportConfiguration().with(source.Tls).newTransport()
+		if err != nil {
+			return nil, fmt.Errorf("cannot create transport for source %s(%s): %w", source.Type, source.Organization, err)
+		}
+		// Images are often served by the same host, so they require the same
+		// TLS configuration as the API.
+		c, err := factory(assetClient.withTransport(transport), transport, source)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create client for source %s(%s): %w", source.Type, source.Organization, err)
+		}
+		result.delegates[i] = compoundClientDelegate{
+			name:       source.name(),
+			sourceType: source.Type,
+			client:     c,
+		}
+	}
+	return result, nil
+}
+
+func (instance sourceConfiguration) name() string {
+	if instance.Name != "" {
+		return instance.Name
+	}
+	if instance.Organization != "" {
+		return instance.Type + ":" + instance.Organization
+	}
+	return instance.Type + ":" + instance.File
+}
+
+func (instance sourceConfiguration) accessToken(def string) string {
+	if instance.TokenEnv != "" {
+		return os.Getenv(instance.TokenEnv)
+	}
+	return def
+}
+
+func (instance sourceConfiguration) api(def string) string {
+	if instance.Api != "" {
+		return instance.Api
+	}
+	return def
+}
+
+func (instance sourceConfiguration) entriesPerPage(def int) int {
+	if instance.EntriesPerPage > 0 {
+		return instance.EntriesPerPage
+	}
+	return def
+}
+
+func (instance sourceConfiguration) maximumNumberOfEntries(def int) int {
+	if instance.MaximumNumberOfEntries != nil {
+		return *instance.MaximumNumberOfEntries
+	}
+	return def
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+)
+
+type configSuite struct{}
+
+var _ = Suite(&configSuite{})
+
+func (s *configSuite) writeFile(c *C, name, content string) string {
+	file := filepath.Join(c.MkDir(), name)
+	c.Assert(os.WriteFile(file, []byte(content), 0644), IsNil)
+	return file
+}
+
+func (s *configSuite) TestLoadYaml(c *C) {
+	file := s.writeFile(c, "config.yml", `
+sources:
+  - type: github
+    organization: foo
+    tokenEnv: FOO_TOKEN
+    maximumNumberOfEntries: 10
+  - type: gitlab
+    organization: "123"
+    baseUrl: https://gitlab.example.org/api/v4
+`)
+
+	actual, err := loadConfigurationFrom(file)
+	c.Assert(err, IsNil)
+	c.Assert(actual.Sources, HasLen, 2)
+	c.Assert(actual.Sources[0].Type, Equals, "github")
+	c.Assert(actual.Sources[0].Organization, Equals, "foo")
+	c.Assert(actual.Sources[0].maximumNumberOfEntries(-1), Equals, 10)
+	c.Assert(actual.Sources[0].entriesPerPage(50), Equals, 50)
+	c.Assert(actual.Sources[1].BaseUrl, Equals, "https://gitlab.example.org/api/v4")
+	c.Assert(actual.Sources[1].maximumNumberOfEntries(-1), Equals, -1)
+}
+
+func (s *configSuite) TestLoadJson(c *C) {
+	file := s.writeFile(c, "config.json", `{"sources": [{"type": "github", "organization": "foo", "entriesPerPage": 5}]}`)
+
+	actual, err := loadConfigurationFrom(file)
+	c.Assert(err, IsNil)
+	c.Assert(actual.Sources, HasLen, 1)
+	c.Assert(actual.Sources[0].entriesPerPage(50), Equals, 5)
+}
+
+func (s *configSuite) TestLoadRejectsUnknownType(c *C) {
+	file := s.writeFile(c, "config.yml", `{"sources": [{"type": "foo", "organization": "bar"}]}`)
+
+	_, err := loadConfigurationFrom(file)
+	c.Assert(err, ErrorMatches, ".*unknown type 'foo'.*")
+}
+
+func (s *configSuite) TestAccessTokenFromEnv(c *C) {
+	c.Assert(os.Setenv("CONFIG_SUITE_TOKEN", "fromEnv"), IsNil)
+	defer func() { _ = os.Unsetenv("CONFIG_SUITE_TOKEN") }()
+
+	c.Assert(sourceConfiguration{TokenEnv: "CONFIG_SUITE_TOKEN"}.accessToken("fromFlag"), Equals, "fromEnv")
+	c.Assert(sourceConfiguration{}.accessToken("fromFlag"), Equals, "fromFlag")
+}
+
+func (s *configSuite) TestNewClientsDownloadAssetsWithTransportOfSource(c *C) {
+	var actualAssetClient *assetClient
+	var actualTransport http.RoundTripper
+	clientFactories["config-suite"] = func(assetClient *assetClient, transport http.RoundTripper, _ sourceConfiguration) (client, error) {
+		actualAssetClient, actualTransport = assetClient, transport
+		return nil, nil
+	}
+	defer delete(clientFactories, "config-suite")
+	assetClient := newAssetClient(http.DefaultTransport)
+
+	_, err := configuration{Sources: []sourceConfiguration{{Type: "config-suite"}}}.newClients(assetClient)
+	c.Assert(err, IsNil)
+
+	c.Assert(actualAssetClient.client.Transport, Equals, actualTransport)
+	c.Assert(actualAssetClient.assetStore, Equals, assetClient.assetStore)
+}
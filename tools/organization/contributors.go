@@ -0,0 +1,216 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	log "github.com/echocat/slf4g"
+)
+
+// contributors configure how the contributors of projects are collected.
+type contributors struct {
+	// Bots are regular expressions which are matched case-insensitively
+	// against the login and name of contributors; matching contributors are
+	// not credited. If absent the defaultContributorBots are used.
+	Bots []string `yaml:"bots"`
+	// Top is the number of contributors kept per project. Defaults to 10.
+	Top *int `yaml:"top"`
+}
+
+// defaultContributorBots match the usual automation accounts. Accounts which
+// GitHub reports as bots are never credited, regardless of their name.
+var defaultContributorBots = []string{
+	`\[bot\]$`,
+	`^dependabot`,
+	`^renovate`,
+	`^github-actions`,
+	`^snyk-bot$`,
+	`-bot$`,
+}
+
+const defaultContributorsTop = 10
+
+type contributor struct {
+	// Identity is the same for all accounts of the same person: the name of
+	// its alias in identities, otherwise "<origin>:<login>" or - for
+	// providers without login - an opaque hash of the reference.
+	Identity        string `json:"identity"`
+	Origin          string `json:"origin"`
+	Login           string `json:"login"`
+	Name            string `json:"name"`
+	ProfileUrl      string `json:"profileUrl"`
+	NumberOfCommits uint32 `json:"numberOfCommits"`
+
+	// email is only reported by providers without login, like GitLab. It is
+	// not part of the output to not publish it.
+	email string
+	// bot is true if the provider reports the account as bot.
+	bot bool
+}
+
+// reference is "<origin>:<login>" or - for providers without login -
+// "<origin>:<email>", for example "gitlab:jdoe@example.org". It must not be
+// published; use identity instead.
+func (instance contributor) reference() string {
+	if instance.Login != "" {
+		return instance.Origin + ":" + instance.Login
+	}
+	if instance.email != "" {
+		return instance.Origin + ":" + strings.ToLower(instance.email)
+	}
+	return instance.Origin + ":" + instance.Name
+}
+
+// identity returns the name of the alias of this contributor or - without
+// alias - its reference. References which contain an email address are
+// replaced by a hash of it.
+func (instance contributor) identity(aliasOf map[string]string) string {
+	reference := instance.reference()
+	if alias, ok := aliasOf[reference]; ok {
+		return alias
+	}
+	if instance.Login != "" {
+		return reference
+	}
+	hash := sha256.Sum256([]byte(reference))
+	return instance.Origin + ":" + hex.EncodeToString(hash[:8])
+}
+
+// contributorsLister returns the contributors of a project, the ones with
+// the most commits first. Only the first page is listed.
+type contributorsLister func() ([]contributor, error)
+
+type rankedContributor struct {
+	contributor
+	NumberOfProjects uint32 `json:"numberOfProjects"`
+}
+
+func (instance contributors) validate() error {
+	for i, pattern := range instance.Bots {
+		if _, err := regexp.Compile("(?i)" + pattern); err != nil {
+			return fmt.Errorf("contributors.bots[%d] is an illegal pattern: %w", i, err)
+		}
+	}
+	if instance.Top != nil && *instance.Top < 0 {
+		return fmt.Errorf("contributors.top must not be negative but is %d", *instance.Top)
+	}
+	return nil
+}
+
+func (instance contributors) top() int {
+	if instance.Top != nil {
+		return *instance.Top
+	}
+	return defaultContributorsTop
+}
+
+// bots requires validate to be called before.
+func (instance contributors) bots() []*regexp.Regexp {
+	patterns := instance.Bots
+	if patterns == nil {
+		patterns = defaultContributorBots
+	}
+	result := make([]*regexp.Regexp, len(patterns))
+	for i, pattern := range patterns {
+		result[i] = regexp.MustCompile("(?i)" + pattern)
+	}
+	return result
+}
+
+func (instance contributors) isBot(candidate contributor, bots []*regexp.Regexp) bool {
+	if candidate.bot {
+		return true
+	}
+	for _, bot := range bots {
+		if (candidate.Login != "" && bot.MatchString(candidate.Login)) || bot.MatchString(candidate.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolve lists the contributors of every project, drops all bots and
+// assigns the identity of every contributor. Only the top contributors are
+// kept in Contributors; all of them are kept for the statistics.
+func (instance contributors) resolve(input projects, identities identities) projects {
+	bots := instance.bots()
+	aliasOf := identities.aliasOf()
+	result, _ := mapConcurrently(input, func(candidate project) (project, error) {
+		if candidate.contributorsLister == nil {
+			return candidate, nil
+		}
+		listed, err := candidate.contributorsLister()
+		if err != nil {
+			log.WithError(err).
+				With("project", candidate.Origin+":"+candidate.Fullname).
+				Warn("Cannot list contributors; the project will not have any.")
+			return candidate, nil
+		}
+		var all []contributor
+		for _, c := range listed {
+			if instance.isBot(c, bots) {
+				continue
+			}
+			c.Identity = c.identity(aliasOf)
+			all = append(all, c)
+		}
+		// The providers report them sorted, but only approximately for
+		// large repositories.
+		sort.SliceStable(all, func(i, j int) bool {
+			return all[i].NumberOfCommits > all[j].NumberOfCommits
+		})
+		candidate.allContributors = all
+		candidate.Contributors = all
+		if len(all) > instance.top() {
+			candidate.Contributors = all[:instance.top()]
+		}
+		candidate.NumberOfContributors = pUint32(uint32(len(all)))
+		return candidate, nil
+	})
+	return result
+}
+
+// rankContributors sums up the commits of every contributor over all
+// visible projects, most commits first.
+func (instance projects) rankContributors() []rankedContributor {
+	byIdentity := map[string]int{}
+	result := []rankedContributor{}
+	for _, candidate := range instance {
+		if candidate.Hidden {
+			continue
+		}
+		all := candidate.allContributors
+		if all == nil {
+			// For example the last known data of a failed source.
+			all = candidate.Contributors
+		}
+		// Several accounts of the same person count as one per project.
+		seen := map[string]bool{}
+		for _, c := range all {
+			if i, ok := byIdentity[c.Identity]; ok {
+				result[i].NumberOfCommits += c.NumberOfCommits
+				if !seen[c.Identity] {
+					result[i].NumberOfProjects++
+				}
+			} else {
+				byIdentity[c.Identity] = len(result)
+				result = append(result, rankedContributor{contributor: c, NumberOfProjects: 1})
+			}
+			seen[c.Identity] = true
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].NumberOfCommits != result[j].NumberOfCommits {
+			return result[i].NumberOfCommits > result[j].NumberOfCommits
+		}
+		if result[i].NumberOfProjects != result[j].NumberOfProjects {
+			return result[i].NumberOfProjects > result[j].NumberOfProjects
+		}
+		return result[i].Identity < result[j].Identity
+	})
+	return result
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+
+	. "gopkg.in/check.v1"
+)
+
+type contributorsSuite struct{}
+
+var _ = Suite(&contributorsSuite{})
+
+func (s *contributorsSuite) TestResolve(c *C) {
+	top := 2
+	instance := contributors{
+		Bots: []string{"^ci$"},
+		Top:  &top,
+	}
+	c.Assert(instance.validate(), IsNil)
+
+	actual := instance.resolve(projects{{
+		Name: "foo",
+		contributorsLister: func() ([]contributor, error) {
+			return []contributor{
+				{Origin: "gitlab", Name: "CI", NumberOfCommits: 100},
+				{Origin: "gitlab", Name: "Jane", NumberOfCommits: 3, email: "Jane@example.org"},
+				{Origin: "gitlab", Name: "John Doe", NumberOfCommits: 7, email: "jdoe@example.org"},
+				{Origin: "gitlab", Name: "renovate-bot", NumberOfCommits: 1},
+				{Origin: "gitlab", Name: "Max", NumberOfCommits: 1},
+			}, nil
+		},
+	}}, identities{Aliases: map[string][]string{
+		"jdoe": {"github:jdoe", "gitlab:jdoe@example.org"},
+	}})
+
+	// Custom bots replace the default ones.
+	c.Assert(actual[0].Contributors, HasLen, 2)
+	c.Assert(actual[0].Contributors[0].Name, Equals, "John Doe")
+	c.Assert(actual[0].Contributors[0].Identity, Equals, "jdoe")
+	c.Assert(actual[0].Contributors[1].Identity, Matches, "gitlab:[0-9a-f]{16}")
+	c.Assert(*actual[0].NumberOfContributors, Equals, uint32(4))
+	c.Assert(actual[0].allContributors, HasLen, 4)
+
+	// Email addresses of contributors are never published.
+	org := organization{Projects: actual}
+	org.align()
+	b, err := json.Marshal(org)
+	c.Assert(err, IsNil)
+	c.Assert(string(b), Not(Matches), "(?is).*example\\.org.*")
+}
+
+func (s *contributorsSuite) TestDefaultBots(c *C) {
+	instance := contributors{}
+	bots := instance.bots()
+
+	c.Assert(instance.isBot(contributor{Login: "dependabot[bot]"}, bots), Equals, true)
+	c.Assert(instance.isBot(contributor{Name: "Renovate Bot"}, bots), Equals, true)
+	c.Assert(instance.isBot(contributor{Login: "echocat-bot"}, bots), Equals, true)
+	c.Assert(instance.isBot(contributor{Login: "acme", bot: true}, bots), Equals, true)
+	c.Assert(instance.isBot(contributor{Login: "jdoe", Name: "Abbot"}, bots), Equals, false)
+}
+
+func (s *contributorsSuite) TestRankContributors(c *C) {
+	jdoeAtGithub := contributor{Identity: "jdoe", Origin: "github", Login: "jdoe", NumberOfCommits: 10}
+	jdoeAtGitlab := contributor{Identity: "jdoe", Origin: "gitlab", Name: "John Doe", NumberOfCommits: 5}
+	jane := contributor{Identity: "github:jane", Origin: "github", Login: "jane", NumberOfCommits: 12}
+
+	org := organization{Projects: projects{
+		{Name: "a", allContributors: []contributor{jdoeAtGithub, jdoeAtGitlab, jane}, Contributors: []contributor{jdoeAtGithub}},
+		// Without allContributors, like the last known data of a source.
+		{Name: "b", Contributors: []contributor{jdoeAtGitlab}},
+		{Name: "c", Hidden: true, Contributors: []contributor{jane}},
+	}}
+	org.align()
+
+	c.Assert(org.Statistics.NumberOfContributors, Equals, uint32(2))
+	c.Assert(org.Statistics.Contributors, DeepEquals, []rankedContributor{
+		{contributor: jdoeAtGithub.withCommits(20), NumberOfProjects: 2},
+		{contributor: jane, NumberOfProjects: 1},
+	})
+}
+
+func (s *contributorsSuite) TestValidate(c *C) {
+	c.Assert(contributors{Bots: []string{"("}}.validate(), ErrorMatches, "contributors.bots\\[0\\] is an illegal pattern: .*")
+	top := -1
+	c.Assert(contributors{Top: &top}.validate(), ErrorMatches, "contributors.top must not be negative but is -1")
+}
+
+func (instance contributor) withCommits(numberOfCommits uint32) contributor {
+	instance.NumberOfCommits = numberOfCommits
+	return instance
+}
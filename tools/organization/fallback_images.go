@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+)
+
+var (
+	fallbackImages = flag.Bool("image-fallbacks", true, "Generate an identicon for every member and project without an image.")
+)
+
+const (
+	identiconCells  = 5
+	identiconCell   = 70
+	identiconMargin = 35
+	// identiconSize is large enough to create all default variants of.
+	identiconSize = identiconCells*identiconCell + 2*identiconMargin
+)
+
+// generateFallbackImages sets a generated identicon as image of every member
+// and project without one. The identicon is derived from the login of the
+// member or the fullname of the project, so it is the same on every run.
+func (instance *assetClient) generateFallbackImages(org organization) (organization, error) {
+	if !*fallbackImages {
+		return org, nil
+	}
+
+	result := org
+	result.Projects = append(projects{}, org.Projects...)
+	for i, project := range result.Projects {
+		if project.ImageAsset != nil {
+			continue
+		}
+		asset, err := instance.retrieveIdenticon(projectAssetOwner(project.Origin, project.Fullname).seed)
+		if err != nil {
+			return organization{}, err
+		}
+		result.Projects[i].ImageAsset = &asset
+	}
+	result.Members = append(members{}, org.Members...)
+	for i, member := range result.Members {
+		if member.ImageAsset != "" {
+			continue
+		}
+		asset, err := instance.retrieveIdenticon(memberAssetOwner(member.Name).seed)
+		if err != nil {
+			return organization{}, err
+		}
+		result.Members[i].ImageAsset = asset
+	}
+	return result, nil
+}
+
+// retrieveIdenticon stores the identicon of the given seed like any other
+// asset and returns its name.
+func (instance *assetClient) retrieveIdenticon(seed string) (string, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, identicon(seed)); err != nil {
+		return "", fmt.Errorf("cannot encode identicon of '%s': %w", seed, err)
+	}
+	sourceRef := "identicon:" + seed
+	asset, hash, err := instance.retrieveFromReader(&buf, sourceRef, ".png", 0)
+	if err != nil {
+		return "", err
+	}
+	instance.remember(sourceRef, assetManifestEntry{
+		Asset:       asset,
+		Hash:        hash,
+		ContentType: "image/png",
+	})
+	return asset, nil
+}
+
+// identicon draws a horizontally symmetric pattern of 5x5 cells. Both the
+// pattern and its color are taken from the SHA-256 of the given seed.
+func identicon(seed string) image.Image {
+	hash := sha256.Sum256([]byte(seed))
+	foreground := colorOfHash(hash)
+	background := color.RGBA{R: 0xf0, G: 0xf0, B: 0xf0, A: 0xff}
+
+	result := image.NewRGBA(image.Rect(0, 0, identiconSize, identiconSize))
+	draw.Draw(result, result.Bounds(), &image.Uniform{C: background}, image.Point{}, draw.Src)
+
+	fill := func(x, y int) {
+		cell := image.Rect(0, 0, identiconCell, identiconCell).
+			Add(image.Pt(identiconMargin+x*identiconCell, identiconMargin+y*identiconCell))
+		draw.Draw(result, cell, &image.Uniform{C: foreground}, image.Point{}, draw.Src)
+	}
+	// The first three bytes are used for the color.
+	for y := 0; y < identiconCells; y++ {
+		for x := 0; x <= identiconCells/2; x++ {
+			if hash[3+y*3+x]&1 == 1 {
+				fill(x, y)
+				fill(identiconCells-1-x, y)
+			}
+		}
+	}
+	return result
+}
+
+// colorOfHash returns a saturated, medium light color whose hue is taken
+// from the given hash.
+func colorOfHash(hash [sha256.Size]byte) color.RGBA {
+	hue := float64(uint16(hash[0])<<8|uint16(hash[1])) / 65536 * 360
+	saturation := 0.45 + float64(hash[2])/255*0.3
+	const lightness = 0.5
+
+	chroma := (1 - math.Abs(2*lightness-1)) * saturation
+	x := chroma * (1 - math.Abs(math.Mod(hue/60, 2)-1))
+	var r, g, b float64
+	switch {
+	case hue < 60:
+		r, g, b = chroma, x, 0
+	case hue < 120:
+		r, g, b = x, chroma, 0
+	case hue < 180:
+		r, g, b = 0, chroma, x
+	case hue < 240:
+		r, g, b = 0, x, chroma
+	case hue < 300:
+		r, g, b = x, 0, chroma
+	default:
+		r, g, b = chroma, 0, x
+	}
+	m := lightness - chroma/2
+	return color.RGBA{
+		R: uint8(math.Round((r + m) * 255)),
+		G: uint8(math.Round((g + m) * 255)),
+		B: uint8(math.Round((b + m) * 255)),
+		A: 0xff,
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"image"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+)
+
+type fallbackImagesSuite struct{}
+
+var _ = Suite(&fallbackImagesSuite{})
+
+func (s *fallbackImagesSuite) TestGeneratesIdenticonsForEntitiesWithoutImage(c *C) {
+	*assetsFolder = c.MkDir()
+	instance := newAssetClient(nil)
+
+	actual, err := instance.generateFallbackImages(organization{
+		Projects: projects{
+			{Origin: "github", Fullname: "echocat/foo"},
+			{Origin: "github", Fullname: "echocat/bar", ImageAsset: pString("bar.png")},
+		},
+		Members: members{
+			{Name: "jdoe"},
+			{Name: "jane"},
+			{Name: "john", ImageAsset: "john.png"},
+		},
+	})
+	c.Assert(err, IsNil)
+
+	c.Assert(*actual.Projects[0].ImageAsset, Matches, "[0-9a-f]{64}\\.png")
+	c.Assert(*actual.Projects[1].ImageAsset, Equals, "bar.png")
+	c.Assert(actual.Members[0].ImageAsset, Matches, "[0-9a-f]{64}\\.png")
+	c.Assert(actual.Members[1].ImageAsset, Not(Equals), actual.Members[0].ImageAsset)
+	c.Assert(actual.Members[2].ImageAsset, Equals, "john.png")
+
+	f, err := os.Open(filepath.Join(*assetsFolder, actual.Members[0].ImageAsset))
+	c.Assert(err, IsNil)
+	defer func() { _ = f.Close() }()
+	config, format, err := image.DecodeConfig(f)
+	c.Assert(err, IsNil)
+	c.Assert(format, Equals, "png")
+	c.Assert(config.Width, Equals, identiconSize)
+	c.Assert(config.Height, Equals, identiconSize)
+
+	// The same login results always in the same image.
+	again, err := newAssetClient(nil).retrieveIdenticon("jdoe")
+	c.Assert(err, IsNil)
+	c.Assert(again, Equals, actual.Members[0].ImageAsset)
+}
+
+func (s *fallbackImagesSuite) TestIdenticonIsSymmetric(c *C) {
+	img := identicon("jdoe")
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += identiconCell / 2 {
+		for x := bounds.Min.X; x < bounds.Max.X/2; x += identiconCell / 2 {
+			c.Assert(img.At(x, y), Equals, img.At(bounds.Max.X-1-x, y))
+		}
+	}
+}
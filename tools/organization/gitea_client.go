@@ -0,0 +1,383 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	giteaEntriesPerPage         = flag.Int("gitea-entriesPerPage", 50, "")
+	giteaMaximumNumberOfEntries = flag.Int("gitea-maximumNumberOfEntries", -1, "")
+	giteaAccessToken            = flag.String("giteaAccessToken", "", "Gitea/Forgejo accessToken to access the API.")
+)
+
+func init() {
+	factory := func(defaultBaseUrl string) clientFactory {
+		return func(assetClient *assetClient, transport http.RoundTripper, source sourceConfiguration) (client, error) {
+			if source.BaseUrl == "" {
+				source.BaseUrl = defaultBaseUrl
+			}
+			if source.BaseUrl == "" {
+				return nil, fmt.Errorf("source of type '%s' requires a baseUrl", source.Type)
+			}
+			return newGiteaClient(assetClient, transport, source), nil
+		}
+	}
+	clientFactories["gitea"] = factory("")
+	clientFactories["forgejo"] = factory("")
+	clientFactories["codeberg"] = factory("https://codeberg.org")
+}
+
+type giteaClient struct {
+	organization           string
+	baseUrl                string
+	accessToken            string
+	entriesPerPage         int
+	maximumNumberOfEntries int
+	assetClient            *assetClient
+	transport              http.RoundTripper
+}
+
+type giteaClientRetrieveTask struct {
+	*giteaClient
+
+	client *restClient
+	ctx    context.Context
+}
+
+type giteaRepository struct {
+	ID          int64    `json:"id"`
+	Name        string   `json:"name"`
+	FullName    string   `json:"full_name"`
+	Description string   `json:"description"`
+	Private     bool     `json:"private"`
+	Fork        bool     `json:"fork"`
+	Archived    bool     `json:"archived"`
+	Mirror      bool     `json:"mirror"`
+	OriginalUrl string   `json:"original_url"`
+	Topics      []string `json:"topics"`
+	// Licenses contains SPDX identifiers; only reported by newer versions.
+	Licenses        []string  `json:"licenses"`
+	HtmlUrl         string    `json:"html_url"`
+	CloneUrl        string    `json:"clone_url"`
+	SshUrl          string    `json:"ssh_url"`
+	Website         string    `json:"website"`
+	Language        string    `json:"language"`
+	AvatarUrl       string    `json:"avatar_url"`
+	DefaultBranch   string    `json:"default_branch"`
+	HasIssues       bool      `json:"has_issues"`
+	HasWiki         bool      `json:"has_wiki"`
+	HasPullRequests bool      `json:"has_pull_requests"`
+	StarsCount      uint32    `json:"stars_count"`
+	ForksCount      uint32    `json:"forks_count"`
+	WatchersCount   uint32    `json:"watchers_count"`
+	OpenIssuesCount uint32    `json:"open_issues_count"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+type giteaUser struct {
+	ID          int64     `json:"id"`
+	Login       string    `json:"login"`
+	FullName    string    `json:"full_name"`
+	Email       string    `json:"email"`
+	AvatarUrl   string    `json:"avatar_url"`
+	HtmlUrl     string    `json:"html_url"`
+	Location    string    `json:"location"`
+	Website     string    `json:"website"`
+	Description string    `json:"description"`
+	Created     time.Time `json:"created"`
+}
+
+func newGiteaClient(assetClient *assetClient, transport http.RoundTripper, source sourceConfiguration) *giteaClient {
+	return &giteaClient{
+		organization:           source.Organization,
+		baseUrl:                strings.TrimSuffix(source.BaseUrl, "/"),
+		accessToken:            source.accessToken(*giteaAccessToken),
+		entriesPerPage:         source.entriesPerPage(*giteaEntriesPerPage),
+		maximumNumberOfEntries: source.maximumNumberOfEntries(*giteaMaximumNumberOfEntries),
+		assetClient:            assetClient,
+		transport:              transport,
+	}
+}
+
+func (instance *giteaClient) retrieveOrganization() (organization, error) {
+	ctx := context.Background()
+
+	task := giteaClientRetrieveTask{
+		giteaClient: instance,
+		client:      instance.newClient(ctx),
+		ctx:         ctx,
+	}
+
+	return task.execute()
+}
+
+func (instance *giteaClientRetrieveTask) execute() (organization, error) {
+	if projects, err := instance.retrieveProjects(); err != nil {
+		return organization{}, err
+	} else if members, err := instance.retrieveMembers(); err != nil {
+		return organization{}, err
+	} else {
+		result := organization{
+			Projects: projects,
+			Members:  members,
+		}
+		result.align()
+		return result, nil
+	}
+}
+
+func (instance *giteaClientRetrieveTask) pageQuery(page int) url.Values {
+	return url.Values{
+		"page":  {strconv.Itoa(page)},
+		"limit": {strconv.Itoa(instance.entriesPerPage)},
+	}
+}
+
+// isLastGiteaPage returns true if there are no entries after the page with
+// the given number of entries; seen is the number of entries of all pages so
+// far. A page with less entries than requested is not necessarily the last
+// one, because the servers cap the limit to their MAX_RESPONSE_ITEMS.
+func isLastGiteaPage(resp *http.Response, entries, seen int) bool {
+	if entries == 0 {
+		return true
+	}
+	if total, err := strconv.Atoi(resp.Header.Get("X-Total-Count")); err == nil {
+		return seen >= total
+	}
+	return false
+}
+
+func (instance *giteaClientRetrieveTask) retrieveMembers() ([]member, error) {
+	var result []member
+	path := "orgs/" + url.PathEscape(instance.organization) + "/public_members"
+	for i, page, seen := 1, 1, 0; instance.maximumNumberOfEntries < 0 || i < instance.maximumNumberOfEntries; page++ {
+		var users []giteaUser
+		resp, err := instance.client.get(instance.ctx, path, instance.pageQuery(page), &users)
+		if err != nil {
+			return result, fmt.Errorf("cannot search for members: %w", err)
+		}
+		for _, user := range users {
+			if instance.maximumNumberOfEntries > 0 && i > instance.maximumNumberOfEntries {
+				break
+			}
+			if member, err := instance.userToMember(user); err != nil {
+				return nil, fmt.Errorf("cannot get details for user '%s': %w", user.Login, err)
+			} else {
+				result = append(result, member)
+			}
+			i++
+		}
+
+		seen += len(users)
+		if isLastGiteaPage(resp, len(users), seen) {
+			break
+		}
+	}
+	return result, nil
+}
+
+func (instance *giteaClientRetrieveTask) userToMember(user giteaUser) (member, error) {
+	name := user.Login
+	fullname := user.FullName
+	if len(fullname) == 0 {
+		fullname = name
+	}
+	profile := user.HtmlUrl
+	if len(profile) == 0 {
+		profile = instance.baseUrl + "/" + url.PathEscape(user.Login)
+	}
+	homepage := user.Website
+	if len(homepage) == 0 {
+		homepage = profile
+	}
+
+	imageAsset := ""
+	if user.AvatarUrl != "" {
+		r, err := instance.assetClient.retrieve(memberAssetOwner(name), user.AvatarUrl)
+		if err != nil {
+			return member{}, err
+		}
+		imageAsset = r
+	}
+
+	var createdAt *time.Time
+	if !user.Created.IsZero() {
+		createdAt = pTime(user.Created)
+	}
+
+	return member{
+		Type:        "user:gitea",
+		Fullname:    fullname,
+		Name:        name,
+		Email:       pNonEmptyString(user.Email),
+		ImageAsset:  imageAsset,
+		ProfileUrl:  profile,
+		Bio:         pNonEmptyString(user.Description),
+		Location:    pNonEmptyString(user.Location),
+		HomepageUrl: &homepage,
+		CreatedAt:   createdAt,
+	}, nil
+}
+
+func (instance *giteaClientRetrieveTask) retrieveProjects() ([]project, error) {
+	var result []project
+	path := "orgs/" + url.PathEscape(instance.organization) + "/repos"
+	for i, page, seen := 1, 1, 0; instance.maximumNumberOfEntries < 0 || i < instance.maximumNumberOfEntries; page++ {
+		var repos []giteaRepository
+		resp, err := instance.client.get(instance.ctx, path, instance.pageQuery(page), &repos)
+		if err != nil {
+			return result, fmt.Errorf("cannot search for repositories: %w", err)
+		}
+		for _, repo := range repos {
+			if instance.maximumNumberOfEntries > 0 && i > instance.maximumNumberOfEntries {
+				break
+			}
+			if !repo.Private {
+				if project, err := instance.repoToProject(repo); err != nil {
+					return nil, fmt.Errorf("cannot get details of project '%s': %w", repo.Name, err)
+				} else {
+					result = append(result, project)
+				}
+				i++
+			}
+		}
+
+		seen += len(repos)
+		if isLastGiteaPage(resp, len(repos), seen) {
+			break
+		}
+	}
+	return result, nil
+}
+
+func (instance *giteaClientRetrieveTask) repoToProject(repo giteaRepository) (project, error) {
+	name := repo.Name
+	fullname := repo.FullName
+	if len(fullname) == 0 {
+		fullname = name
+	}
+	homepage := repo.Website
+	if len(homepage) == 0 {
+		homepage = repo.HtmlUrl
+	}
+	issuesUrl := ""
+	if repo.HasIssues {
+		issuesUrl = repo.HtmlUrl + "/issues"
+	}
+	wikiUrl := ""
+	if repo.HasWiki {
+		wikiUrl = repo.HtmlUrl + "/wiki"
+	}
+	pullRequestsUrl := ""
+	if repo.HasPullRequests {
+		pullRequestsUrl = repo.HtmlUrl + "/pulls"
+	}
+	forksUrl := repo.HtmlUrl + "/forks"
+	createForkUrl := repo.HtmlUrl + "/fork"
+	starsUrl := repo.HtmlUrl + "/stars"
+	watchersUrl := repo.HtmlUrl + "/watchers"
+	mirrorOf := ""
+	if repo.Mirror {
+		mirrorOf = repo.OriginalUrl
+	}
+
+	imageAsset := ""
+	if repo.AvatarUrl != "" {
+		r, err := instance.assetClient.retrieve(projectAssetOwner("gitea", fullname), repo.AvatarUrl)
+		if err != nil {
+			return project{}, err
+		}
+		imageAsset = r
+	}
+
+	return project{
+		Type:               "repository:git:gitea",
+		Origin:             "gitea",
+		Fullname:           fullname,
+		Name:               name,
+		Description:        pNonEmptyString(repo.Description),
+		DefaultBranch:      pNonEmptyString(repo.DefaultBranch),
+		Language:           pNonEmptyString(canonicalLanguage(repo.Language)),
+		HomepageUrl:        &homepage,
+		ImageAsset:         pNonEmptyString(imageAsset),
+		ProfileUrl:         repo.HtmlUrl,
+		HttpCloneUrl:       pNonEmptyString(repo.CloneUrl),
+		SshCloneUrl:        pNonEmptyString(repo.SshUrl),
+		IssuesUrl:          pNonEmptyString(issuesUrl),
+		WikiUrl:            pNonEmptyString(wikiUrl),
+		PullRequestsUrl:    pNonEmptyString(pullRequestsUrl),
+		ForksUrl:           &forksUrl,
+		CreateForkUrl:      &createForkUrl,
+		StarsUrl:           &starsUrl,
+		WatchersUrl:        &watchersUrl,
+		NumberOfForks:      pUint32(repo.ForksCount),
+		NumberOfOpenIssues: pUint32(repo.OpenIssuesCount),
+		NumberOfStars:      pUint32(repo.StarsCount),
+		NumberOfWatchers:   pUint32(repo.WatchersCount),
+		CreatedAt:          pTime(repo.CreatedAt),
+		UpdatedAt:          pTime(repo.UpdatedAt),
+		Topics:             repo.Topics,
+		License:            instance.licenseOf(repo),
+		Fork:               repo.Fork,
+		Archived:           repo.Archived,
+		mirrorOf:           mirrorOf,
+		fileFetcher:        instance.fileFetcherOf(repo),
+	}, nil
+}
+
+func (instance *giteaClientRetrieveTask) licenseOf(repo giteaRepository) *license {
+	if len(repo.Licenses) == 0 {
+		return nil
+	}
+	return licenseOf(repo.Licenses[0], "")
+}
+
+func (instance *giteaClientRetrieveTask) fileFetcherOf(repo giteaRepository) fileFetcher {
+	return func(file string) (string, bool, error) {
+		var content struct {
+			Type     string `json:"type"`
+			Encoding string `json:"encoding"`
+			Content  string `json:"content"`
+		}
+		query := url.Values{}
+		if repo.DefaultBranch != "" {
+			query.Set("ref", repo.DefaultBranch)
+		}
+		path := "repos/" + repo.FullName + "/contents/" + url.PathEscape(file)
+		resp, err := instance.client.get(instance.ctx, path, query, &content)
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return "", false, nil
+		}
+		if err != nil {
+			return "", false, fmt.Errorf("cannot get %s of Gitea repository %s: %w", file, repo.FullName, err)
+		}
+		if content.Type != "file" {
+			return "", false, nil
+		}
+		if content.Encoding != "base64" {
+			return content.Content, true, nil
+		}
+		b, err := base64.StdEncoding.DecodeString(content.Content)
+		if err != nil {
+			return "", false, fmt.Errorf("cannot decode %s of Gitea repository %s: %w", file, repo.FullName, err)
+		}
+		return string(b), true, nil
+	}
+}
+
+func (instance *giteaClient) newClient(_ context.Context) *restClient {
+	headers := http.Header{}
+	if len(instance.accessToken) > 0 {
+		headers.Set("Authorization", "token "+instance.accessToken)
+	}
+	return newRestClient(instance.baseUrl+"/api/v1", headers, instance.transport)
+}
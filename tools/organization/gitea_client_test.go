@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	. "gopkg.in/check.v1"
+)
+
+type giteaClientSuite struct {
+	server *httptest.Server
+}
+
+var _ = Suite(&giteaClientSuite{})
+
+func (s *giteaClientSuite) SetUpTest(c *C) {
+	*assetsFolder = c.MkDir()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/orgs/acme/repos", func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Header.Get("Authorization"), Equals, "token secret")
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Query().Get("page") {
+		case "1":
+			_, _ = w.Write([]byte(`[
+				{"id": 1, "name": "foo", "full_name": "acme/foo", "description": "Foo!", "html_url": "` + s.server.URL + `/acme/foo",
+				 "clone_url": "` + s.server.URL + `/acme/foo.git", "ssh_url": "git@example.org:acme/foo.git", "language": "Go",
+				 "has_issues": true, "has_pull_requests": true, "stars_count": 3, "forks_count": 2, "watchers_count": 1, "open_issues_count": 4,
+				 "avatar_url": "` + s.server.URL + `/avatar.png", "created_at": "2020-01-02T03:04:05Z", "updated_at": "2021-01-02T03:04:05Z",
+				 "licenses": ["apache-2.0"]},
+				{"id": 2, "name": "old", "full_name": "acme/old", "archived": true}
+			]`))
+		case "2":
+			_, _ = w.Write([]byte(`[
+				{"id": 3, "name": "bar", "full_name": "acme/bar", "html_url": "` + s.server.URL + `/acme/bar", "website": "https://bar.example.org",
+				 "created_at": "2020-01-02T03:04:05Z", "updated_at": "2022-01-02T03:04:05Z"}
+			]`))
+		default:
+			_, _ = w.Write([]byte(`[]`))
+		}
+	})
+	mux.HandleFunc("/api/v1/orgs/acme/public_members", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("page") != "1" {
+			_, _ = w.Write([]byte(`[]`))
+			return
+		}
+		_, _ = w.Write([]byte(`[
+			{"id": 10, "login": "jdoe", "full_name": "John Doe", "location": "Berlin", "avatar_url": "` + s.server.URL + `/avatar.png"},
+			{"id": 11, "login": "jane"}
+		]`))
+	})
+	mux.HandleFunc("/api/v1/repos/acme/bar/contents/COPYING", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"type": "file", "encoding": "base64", "content": "ICAgICAgICAgICAgICAgICAgICBHTlUgR0VORVJBTCBQVUJMSUMgTElDRU5TRQogICAgICAgICAgICAgICAgICAgICAgIFZlcnNpb24gMywgMjkgSnVuZSAyMDA3Cg=="}`))
+	})
+	mux.HandleFunc("/avatar.png", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write([]byte(pngSignature + "not really a png"))
+	})
+	s.server = httptest.NewServer(mux)
+}
+
+func (s *giteaClientSuite) TearDownTest(_ *C) {
+	s.server.Close()
+}
+
+func (s *giteaClientSuite) TestRetrieveOrganization(c *C) {
+	c.Assert(os.Setenv("GITEA_SUITE_TOKEN", "secret"), IsNil)
+	defer func() { _ = os.Unsetenv("GITEA_SUITE_TOKEN") }()
+	instance := newGiteaClient(newAssetClient(http.DefaultTransport), http.DefaultTransport, sourceConfiguration{
+		Type:           "gitea",
+		Organization:   "acme",
+		BaseUrl:        s.server.URL + "/",
+		EntriesPerPage: 2,
+		TokenEnv:       "GITEA_SUITE_TOKEN",
+	})
+
+	actual, err := instance.retrieveOrganization()
+	c.Assert(err, IsNil)
+
+	c.Assert(actual.Projects, HasLen, 3)
+	c.Assert(actual.Projects[2].Name, Equals, "old")
+	c.Assert(actual.Projects[2].Archived, Equals, true)
+	c.Assert(actual.Projects[0].Name, Equals, "bar")
+	c.Assert(*actual.Projects[0].HomepageUrl, Equals, "https://bar.example.org")
+	c.Assert(actual.Projects[0].IssuesUrl, IsNil)
+	foo := actual.Projects[1]
+	c.Assert(foo.Type, Equals, "repository:git:gitea")
+	c.Assert(foo.Origin, Equals, "gitea")
+	c.Assert(foo.Fullname, Equals, "acme/foo")
+	c.Assert(*foo.Language, Equals, "Golang")
+	c.Assert(*foo.IssuesUrl, Equals, s.server.URL+"/acme/foo/issues")
+	c.Assert(*foo.PullRequestsUrl, Equals, s.server.URL+"/acme/foo/pulls")
+	c.Assert(*foo.NumberOfStars, Equals, uint32(3))
+	c.Assert(*foo.NumberOfOpenIssues, Equals, uint32(4))
+	c.Assert(foo.ImageAsset, NotNil)
+	c.Assert(foo.License.SpdxId, Equals, "Apache-2.0")
+
+	// The license files of the other projects are classified.
+	resolved := resolveLicenses(actual.Projects)
+	c.Assert(resolved[0].License.SpdxId, Equals, "GPL-3.0")
+	c.Assert(resolved[2].License, IsNil)
+	_, err = os.Stat(filepath.Join(*assetsFolder, *foo.ImageAsset))
+	c.Assert(err, IsNil)
+
+	c.Assert(actual.Members, HasLen, 2)
+	c.Assert(actual.Members[0].Type, Equals, "user:gitea")
+	c.Assert(actual.Members[0].Fullname, Equals, "John Doe")
+	c.Assert(*actual.Members[0].Location, Equals, "Berlin")
+	c.Assert(actual.Members[0].ImageAsset, Equals, *foo.ImageAsset)
+	c.Assert(actual.Members[1].Name, Equals, "jane")
+	c.Assert(actual.Members[1].ProfileUrl, Equals, s.server.URL+"/jane")
+	c.Assert(actual.Members[1].ImageAsset, Equals, "")
+}
+
+func (s *giteaClientSuite) TestPagesThroughServersWhichCapTheLimit(c *C) {
+	// Mimics a server with MAX_RESPONSE_ITEMS=1, which ignores the greater limit.
+	users := []string{`{"id": 10, "login": "jdoe"}`, `{"id": 11, "login": "jane"}`}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Path, Equals, "/api/v1/orgs/acme/public_members")
+		c.Check(r.URL.Query().Get("limit"), Equals, "50")
+		page, err := strconv.Atoi(r.URL.Query().Get("page"))
+		c.Assert(err, IsNil)
+		c.Check(page <= len(users), Equals, true, Commentf("requested page %d after the total count", page))
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Total-Count", strconv.Itoa(len(users)))
+		_, _ = w.Write([]byte(`[` + users[page-1] + `]`))
+	}))
+	defer server.Close()
+	instance := newGiteaClient(newAssetClient(http.DefaultTransport), http.DefaultTransport, sourceConfiguration{
+		Type:           "gitea",
+		Organization:   "acme",
+		BaseUrl:        server.URL + "/",
+		EntriesPerPage: 50,
+	})
+	ctx := context.Background()
+	task := giteaClientRetrieveTask{giteaClient: instance, client: instance.newClient(ctx), ctx: ctx}
+
+	actual, err := task.retrieveMembers()
+	c.Assert(err, IsNil)
+	c.Assert(actual, HasLen, 2)
+	c.Assert(actual[0].Name, Equals, "jdoe")
+	c.Assert(actual[1].Name, Equals, "jane")
+}
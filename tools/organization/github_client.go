@@ -5,7 +5,10 @@ import (
 	"flag"
 	"fmt"
 	"net/http"
+	"net/url"
+	"time"
 
+	log "github.com/echocat/slf4g"
 	"github.com/google/go-github/v50/github"
 	"golang.org/x/oauth2"
 )
@@ -14,11 +17,31 @@ var (
 	githubEntriesPerPage         = flag.Int("github-entriesPerPage", 50, "")
 	githubMaximumNumberOfEntries = flag.Int("github-maximumNumberOfEntries", -1, "")
 	githubAccessToken            = flag.String("githubAccessToken", "", "Github accessToken to access the API.")
+	githubApi                    = flag.String("github-api", "rest", "API to retrieve the organization from GitHub with: rest or graphql. graphql requires an accessToken.")
 )
 
+func init() {
+	clientFactories["github"] = func(assetClient *assetClient, transport http.RoundTripper, source sourceConfiguration) (client, error) {
+		result := newGithubClient(assetClient, transport, source)
+		if result.api != "rest" && result.api != "graphql" {
+			return nil, fmt.Errorf("source of type '%s' does not support the api '%s'", source.Type, result.api)
+		}
+		if result.api == "graphql" && result.accessToken == "" {
+			return nil, fmt.Errorf("source of type '%s' requires an accessToken for the api '%s'", source.Type, result.api)
+		}
+		return result, nil
+	}
+}
+
 type githubClient struct {
-	accessToken string
-	assetClient *assetClient
+	organization           string
+	baseUrl                string
+	accessToken            string
+	api                    string
+	entriesPerPage         int
+	maximumNumberOfEntries int
+	assetClient            *assetClient
+	transport              http.RoundTripper
 }
 
 type githubClientRetrieveTask struct {
@@ -28,22 +51,40 @@ type githubClientRetrieveTask struct {
 	ctx    context.Context
 }
 
-func newGithubClient(assetClient *assetClient) *githubClient {
+func newGithubClient(assetClient *assetClient, transport http.RoundTripper, source sourceConfiguration) *githubClient {
 	return &githubClient{
-		assetClient: assetClient,
-		accessToken: *githubAccessToken,
+		organization:           source.Organization,
+		baseUrl:                source.BaseUrl,
+		accessToken:            source.accessToken(*githubAccessToken),
+		api:                    source.api(*githubApi),
+		entriesPerPage:         source.entriesPerPage(*githubEntriesPerPage),
+		maximumNumberOfEntries: source.maximumNumberOfEntries(*githubMaximumNumberOfEntries),
+		assetClient:            assetClient,
+		transport:              transport,
 	}
 }
 
 func (instance *githubClient) retrieveOrganization() (organization, error) {
 	ctx := context.Background()
 
+	c, err := instance.newClient(ctx)
+	if err != nil {
+		return organization{}, fmt.Errorf("cannot create GitHub client: %w", err)
+	}
+
 	task := githubClientRetrieveTask{
 		githubClient: instance,
-		client:       instance.newClient(ctx),
+		client:       c,
 		ctx:          ctx,
 	}
 
+	if instance.api == "graphql" {
+		graphqlTask := githubGraphqlRetrieveTask{
+			githubClientRetrieveTask: &task,
+			graphql:                  instance.newGraphqlClient(),
+		}
+		return graphqlTask.execute()
+	}
 	return task.execute()
 }
 
@@ -63,25 +104,21 @@ func (instance *githubClientRetrieveTask) execute() (organization, error) {
 }
 
 func (instance *githubClientRetrieveTask) retrieveMembers() ([]member, error) {
-	var result []member
+	var candidates []*github.User
 	opt := &github.ListMembersOptions{
-		ListOptions: github.ListOptions{PerPage: *githubEntriesPerPage},
+		ListOptions: github.ListOptions{PerPage: instance.entriesPerPage},
 		PublicOnly:  true,
 	}
-	for i := 1; *githubMaximumNumberOfEntries < 0 || i < *githubMaximumNumberOfEntries; {
-		users, resp, err := instance.client.Organizations.ListMembers(instance.ctx, "echocat", opt)
+	for i := 1; instance.maximumNumberOfEntries < 0 || i < instance.maximumNumberOfEntries; {
+		users, resp, err := instance.client.Organizations.ListMembers(instance.ctx, instance.organization, opt)
 		if err != nil {
-			return result, fmt.Errorf("cannot search for users: %v", err)
+			return nil, fmt.Errorf("cannot search for users: %v", err)
 		}
 		for _, user := range users {
-			if *githubMaximumNumberOfEntries > 0 && i > *githubMaximumNumberOfEntries {
+			if instance.maximumNumberOfEntries > 0 && i > instance.maximumNumberOfEntries {
 				break
 			}
-			if member, err := instance.userToMember(*user); err != nil {
-				return nil, fmt.Errorf("cannot get details for user '%s': %w", *user.Name, err)
-			} else {
-				result = append(result, member)
-			}
+			candidates = append(candidates, user)
 			i++
 		}
 
@@ -90,7 +127,13 @@ func (instance *githubClientRetrieveTask) retrieveMembers() ([]member, error) {
 		}
 		opt.Page = resp.NextPage
 	}
-	return result, nil
+	return mapConcurrently(candidates, func(user *github.User) (member, error) {
+		if member, err := instance.userToMember(*user); err != nil {
+			return member, fmt.Errorf("cannot get details for user '%s': %w", user.GetLogin(), err)
+		} else {
+			return member, nil
+		}
+	})
 }
 
 func (instance *githubClientRetrieveTask) detailsOfUser(input github.User) (github.User, error) {
@@ -117,22 +160,18 @@ func (instance *githubClientRetrieveTask) userToMember(repo github.User) (member
 
 		imageAsset := ""
 		if avatarUrl := detailed.GetAvatarURL(); avatarUrl != "" {
-			r, err := instance.assetClient.retrieve(avatarUrl)
+			r, err := instance.assetClient.retrieve(memberAssetOwner(name), avatarUrl)
 			if err != nil {
 				return member{}, err
 			}
 			imageAsset = r
 		}
-		avatarUrl := detailed.GetAvatarURL()
-		if len(avatarUrl) == 0 {
-			avatarUrl = "https://www.gravatar.com/avatar/00000000000000000000000000000000"
-		}
 
 		return member{
 			Type:        "user:github",
 			Fullname:    fullname,
 			Name:        name,
-			Email:       pString(detailed.GetEmail()),
+			Email:       pNonEmptyString(detailed.GetEmail()),
 			ImageAsset:  imageAsset,
 			ProfileUrl:  detailed.GetHTMLURL(),
 			Bio:         pString(detailed.GetBio()),
@@ -141,31 +180,29 @@ func (instance *githubClientRetrieveTask) userToMember(repo github.User) (member
 			HomepageUrl: &homepage,
 			CreatedAt:   pTime(detailed.GetCreatedAt().Time),
 			UpdatedAt:   pTime(detailed.GetUpdatedAt().Time),
+			// GitHub only allows verified addresses as public email.
+			EmailVerified: true,
 		}, nil
 	}
 }
 
 func (instance *githubClientRetrieveTask) retrieveProjects() ([]project, error) {
-	var result []project
+	var candidates []*github.Repository
 	opt := &github.RepositoryListOptions{
-		ListOptions: github.ListOptions{PerPage: *githubEntriesPerPage},
+		ListOptions: github.ListOptions{PerPage: instance.entriesPerPage},
 		Visibility:  "public",
 	}
-	for i := 1; *githubMaximumNumberOfEntries < 0 || i < *githubMaximumNumberOfEntries; {
-		repos, resp, err := instance.client.Repositories.List(instance.ctx, "echocat", opt)
+	for i := 1; instance.maximumNumberOfEntries < 0 || i < instance.maximumNumberOfEntries; {
+		repos, resp, err := instance.client.Repositories.List(instance.ctx, instance.organization, opt)
 		if err != nil {
-			return result, fmt.Errorf("cannot search for users: %v", err)
+			return nil, fmt.Errorf("cannot search for users: %v", err)
 		}
 		for _, repo := range repos {
-			if *githubMaximumNumberOfEntries > 0 && i > *githubMaximumNumberOfEntries {
+			if instance.maximumNumberOfEntries > 0 && i > instance.maximumNumberOfEntries {
 				break
 			}
-			if !repo.GetArchived() && repo.Name != nil {
-				if project, err := instance.repoToProject(*repo); err != nil {
-					return nil, fmt.Errorf("cannot get details of project '%s': %w", *repo.Name, err)
-				} else {
-					result = append(result, project)
-				}
+			if repo.Name != nil {
+				candidates = append(candidates, repo)
 				i++
 			}
 		}
@@ -175,7 +212,13 @@ func (instance *githubClientRetrieveTask) retrieveProjects() ([]project, error)
 		}
 		opt.Page = resp.NextPage
 	}
-	return result, nil
+	return mapConcurrently(candidates, func(repo *github.Repository) (project, error) {
+		if project, err := instance.repoToProject(*repo); err != nil {
+			return project, fmt.Errorf("cannot get details of project '%s': %w", repo.GetName(), err)
+		} else {
+			return project, nil
+		}
+	})
 }
 
 func (instance *githubClientRetrieveTask) detailsOfProject(input github.Repository) (github.Repository, error) {
@@ -186,10 +229,24 @@ func (instance *githubClientRetrieveTask) detailsOfProject(input github.Reposito
 	}
 }
 
+func (instance *githubClientRetrieveTask) languagesOfProject(input github.Repository) (map[string]float64, error) {
+	if result, _, err := instance.client.Repositories.ListLanguages(instance.ctx, input.GetOwner().GetLogin(), input.GetName()); err != nil {
+		return nil, fmt.Errorf("cannot get languages of GitHub repository %s/%s(%d): %v", input.GetOwner().GetLogin(), input.GetName(), input.GetID(), err)
+	} else {
+		return languagesOfBytes(result), nil
+	}
+}
+
 func (instance *githubClientRetrieveTask) repoToProject(repo github.Repository) (project, error) {
 	if detailed, err := instance.detailsOfProject(repo); err != nil {
 		return project{}, err
 	} else {
+		languages, err := instance.languagesOfProject(detailed)
+		if err != nil {
+			log.WithError(err).
+				With("project", "github:"+detailed.GetFullName()).
+				Warn("Cannot get languages; only the dominant language will be known.")
+		}
 		name := detailed.GetName()
 		fullname := detailed.GetFullName()
 		if len(fullname) == 0 {
@@ -220,7 +277,8 @@ func (instance *githubClientRetrieveTask) repoToProject(repo github.Repository)
 			Name:               name,
 			Description:        pString(detailed.GetDescription()),
 			DefaultBranch:      pString(detailed.GetDefaultBranch()),
-			Language:           pString(detailed.GetLanguage()),
+			Language:           pString(canonicalLanguage(detailed.GetLanguage())),
+			Languages:          languages,
 			HomepageUrl:        &homepage,
 			ProfileUrl:         detailed.GetHTMLURL(),
 			HttpCloneUrl:       pString(detailed.GetCloneURL()),
@@ -238,17 +296,169 @@ func (instance *githubClientRetrieveTask) repoToProject(repo github.Repository)
 			NumberOfWatchers:   pUint32(uint32(detailed.GetWatchersCount())),
 			CreatedAt:          pTime(detailed.GetCreatedAt().Time),
 			UpdatedAt:          pTime(detailed.GetPushedAt().Time),
+			Topics:             detailed.Topics,
+			License:            licenseOf(detailed.GetLicense().GetSPDXID(), detailed.GetLicense().GetName()),
+			Fork:               detailed.GetFork(),
+			Archived:           detailed.GetArchived(),
+			mirrorOf:           detailed.GetMirrorURL(),
+			rootCommitResolver: instance.rootCommitResolverOf(detailed),
+			fileFetcher:        instance.fileFetcherOf(detailed.GetOwner().GetLogin(), detailed.GetName(), detailed.GetDefaultBranch()),
+			releasesLister:     instance.releasesListerOf(detailed.GetOwner().GetLogin(), detailed.GetName()),
+			tagsLister:         instance.tagsListerOf(detailed.GetOwner().GetLogin(), detailed.GetName(), detailed.GetHTMLURL()),
+			contributorsLister: instance.contributorsListerOf(detailed.GetOwner().GetLogin(), detailed.GetName()),
 		}, nil
 	}
 }
 
-func (instance *githubClient) newClient(ctx context.Context) *github.Client {
-	var httpClient *http.Client
+func (instance *githubClientRetrieveTask) rootCommitResolverOf(repo github.Repository) func() (string, error) {
+	return func() (string, error) {
+		owner, name := repo.GetOwner().GetLogin(), repo.GetName()
+		// The commits are listed newest first; with one commit per page the
+		// last page contains the root commit.
+		opt := &github.CommitsListOptions{
+			SHA:         repo.GetDefaultBranch(),
+			ListOptions: github.ListOptions{PerPage: 1},
+		}
+		commits, resp, err := instance.client.Repositories.ListCommits(instance.ctx, owner, name, opt)
+		if err != nil {
+			return "", fmt.Errorf("cannot list commits of GitHub repository %s/%s: %v", owner, name, err)
+		}
+		if resp.LastPage > 0 {
+			opt.Page = resp.LastPage
+			if commits, _, err = instance.client.Repositories.ListCommits(instance.ctx, owner, name, opt); err != nil {
+				return "", fmt.Errorf("cannot list commits of GitHub repository %s/%s: %v", owner, name, err)
+			}
+		}
+		if len(commits) == 0 {
+			return "", nil
+		}
+		return commits[0].GetSHA(), nil
+	}
+}
+
+func (instance *githubClientRetrieveTask) fileFetcherOf(owner, name, ref string) fileFetcher {
+	return func(file string) (string, bool, error) {
+		content, _, resp, err := instance.client.Repositories.GetContents(instance.ctx, owner, name, file, &github.RepositoryContentGetOptions{Ref: ref})
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return "", false, nil
+		}
+		if err != nil {
+			return "", false, fmt.Errorf("cannot get %s of GitHub repository %s/%s: %v", file, owner, name, err)
+		}
+		if content == nil {
+			// It is a directory.
+			return "", false, nil
+		}
+		result, err := content.GetContent()
+		if err != nil {
+			return "", false, fmt.Errorf("cannot decode %s of GitHub repository %s/%s: %v", file, owner, name, err)
+		}
+		return result, true, nil
+	}
+}
+
+func (instance *githubClientRetrieveTask) releasesListerOf(owner, name string) releasesLister {
+	return func() ([]release, error) {
+		releases, _, err := instance.client.Repositories.ListReleases(instance.ctx, owner, name, &github.ListOptions{PerPage: 100})
+		if err != nil {
+			return nil, fmt.Errorf("cannot list releases of GitHub repository %s/%s: %v", owner, name, err)
+		}
+		result := make([]release, 0, len(releases))
+		for _, candidate := range releases {
+			if candidate.GetDraft() {
+				continue
+			}
+			var publishedAt *time.Time
+			if candidate.PublishedAt != nil {
+				publishedAt = pTime(candidate.GetPublishedAt().Time)
+			}
+			name := candidate.GetName()
+			if name == "" {
+				name = candidate.GetTagName()
+			}
+			result = append(result, release{
+				Tag:         candidate.GetTagName(),
+				Name:        name,
+				PublishedAt: publishedAt,
+				Url:         candidate.GetHTMLURL(),
+				Prerelease:  candidate.GetPrerelease(),
+			})
+		}
+		return result, nil
+	}
+}
+
+// tagsListerOf only lists the semver tags, newest version first. GitHub only
+// reports the date of the tagged commit per commit, so it is resolved for the
+// newest tags until the first stable one which is not recent anymore.
+func (instance *githubClientRetrieveTask) tagsListerOf(owner, name, htmlUrl string) releasesLister {
+	return func() ([]release, error) {
+		tags, _, err := instance.client.Repositories.ListTags(instance.ctx, owner, name, &github.ListOptions{PerPage: 100})
+		if err != nil {
+			return nil, fmt.Errorf("cannot list tags of GitHub repository %s/%s: %v", owner, name, err)
+		}
+		commitOf := make(map[string]string, len(tags))
+		all := make([]release, len(tags))
+		for i, tag := range tags {
+			commitOf[tag.GetName()] = tag.GetCommit().GetSHA()
+			all[i] = release{
+				Tag:  tag.GetName(),
+				Name: tag.GetName(),
+				Url:  htmlUrl + "/releases/tag/" + url.PathEscape(tag.GetName()),
+			}
+		}
+		result := semverReleasesOf(all)
+		now := time.Now()
+		for i, candidate := range result {
+			commit, _, err := instance.client.Repositories.GetCommit(instance.ctx, owner, name, commitOf[candidate.Tag], nil)
+			if err != nil {
+				return nil, fmt.Errorf("cannot get commit of tag %s of GitHub repository %s/%s: %v", candidate.Tag, owner, name, err)
+			}
+			if date := commit.GetCommit().GetCommitter().Date; date != nil {
+				result[i].PublishedAt = pTime(date.Time)
+				if !candidate.Prerelease && now.Sub(date.Time) > recentReleasesPeriod {
+					break
+				}
+			}
+		}
+		return result, nil
+	}
+}
+
+func (instance *githubClientRetrieveTask) contributorsListerOf(owner, name string) contributorsLister {
+	return func() ([]contributor, error) {
+		contributors, _, err := instance.client.Repositories.ListContributors(instance.ctx, owner, name, &github.ListContributorsOptions{
+			ListOptions: github.ListOptions{PerPage: 100},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("cannot list contributors of GitHub repository %s/%s: %v", owner, name, err)
+		}
+		result := make([]contributor, len(contributors))
+		for i, candidate := range contributors {
+			result[i] = contributor{
+				Origin:          "github",
+				Login:           candidate.GetLogin(),
+				Name:            candidate.GetLogin(),
+				ProfileUrl:      candidate.GetHTMLURL(),
+				NumberOfCommits: uint32(candidate.GetContributions()),
+				bot:             candidate.GetType() == "Bot",
+			}
+		}
+		return result, nil
+	}
+}
+
+func (instance *githubClient) newClient(ctx context.Context) (*github.Client, error) {
+	httpClient := &http.Client{Transport: instance.transport}
 	if len(instance.accessToken) > 0 {
 		ts := oauth2.StaticTokenSource(
 			&oauth2.Token{AccessToken: instance.accessToken},
 		)
-		httpClient = oauth2.NewClient(ctx, ts)
+		// oauth2 uses the client of the context as base for its transport.
+		httpClient = oauth2.NewClient(context.WithValue(ctx, oauth2.HTTPClient, httpClient), ts)
+	}
+	if instance.baseUrl != "" {
+		return github.NewEnterpriseClient(instance.baseUrl, instance.baseUrl, httpClient)
 	}
-	return github.NewClient(httpClient)
+	return github.NewClient(httpClient), nil
 }
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type githubClientSuite struct {
+	server *httptest.Server
+	// requests are the number of requests per path.
+	requests sync.Map
+	// failLanguages lets the languages of acme/foo fail.
+	failLanguages bool
+}
+
+var _ = Suite(&githubClientSuite{})
+
+func (s *githubClientSuite) SetUpTest(c *C) {
+	*assetsFolder = c.MkDir()
+
+	yesterday := time.Now().Add(-24 * time.Hour).UTC().Format(time.RFC3339)
+	fixture := func(name string) http.HandlerFunc {
+		return func(w http.ResponseWriter, _ *http.Request) {
+			b, err := os.ReadFile(filepath.Join("testdata", "github", name))
+			c.Assert(err, IsNil)
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(strings.NewReplacer(
+				"{{server}}", s.server.URL,
+				"{{yesterday}}", yesterday,
+			).Replace(string(b))))
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v3/users/acme/repos", fixture("rest/repos.json"))
+	mux.HandleFunc("/api/v3/repositories/1", fixture("rest/repository-1.json"))
+	mux.HandleFunc("/api/v3/repositories/2", fixture("rest/repository-2.json"))
+	mux.HandleFunc("/api/v3/orgs/acme/public_members", fixture("rest/public_members.json"))
+	mux.HandleFunc("/api/v3/user/11", fixture("rest/user-11.json"))
+	mux.HandleFunc("/api/v3/user/12", fixture("rest/user-12.json"))
+	mux.HandleFunc("/api/v3/repos/acme/bar/contents/LICENSE", func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Query().Get("ref"), Equals, "master")
+		fixture("rest/contents-bar-LICENSE.json")(w, r)
+	})
+	mux.HandleFunc("/api/v3/repos/acme/foo/languages", func(w http.ResponseWriter, _ *http.Request) {
+		if s.failLanguages {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write([]byte(`{"Go": 3000, "Shell": 1000}`))
+	})
+	mux.HandleFunc("/api/v3/repos/acme/bar/languages", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{}`))
+	})
+	mux.HandleFunc("/api/v3/repos/acme/foo/releases", fixture("rest/releases-foo.json"))
+	mux.HandleFunc("/api/v3/repos/acme/bar/releases", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("[]"))
+	})
+	mux.HandleFunc("/api/v3/repos/acme/bar/tags", fixture("rest/tags-bar.json"))
+	mux.HandleFunc("/api/v3/repos/acme/bar/commits/", func(w http.ResponseWriter, r *http.Request) {
+		date, ok := map[string]string{
+			"c4": yesterday,
+			"c1": "2022-05-01T10:00:00Z",
+		}[strings.TrimPrefix(r.URL.Path, "/api/v3/repos/acme/bar/commits/")]
+		// Commits of older tags are not requested.
+		c.Check(ok, Equals, true, Commentf("%s", r.URL.Path))
+		_, _ = fmt.Fprintf(w, `{"commit": {"committer": {"date": %q}}}`, date)
+	})
+	mux.HandleFunc("/api/v3/repos/acme/foo/contributors", fixture("rest/contributors-foo.json"))
+	mux.HandleFunc("/api/v3/repos/acme/bar/contributors", func(w http.ResponseWriter, _ *http.Request) {
+		// GitHub's answer for empty repositories.
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("/api/graphql", func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Header.Get("Authorization"), Equals, "bearer secret")
+		b, err := io.ReadAll(r.Body)
+		c.Assert(err, IsNil)
+		var request graphqlRequest
+		c.Assert(json.Unmarshal(b, &request), IsNil)
+		switch {
+		case strings.Contains(request.Query, "organization(login:"):
+			c.Check(request.Variables["login"], Equals, "acme")
+			fixture("graphql/repositories.json")(w, r)
+		case strings.Contains(request.Query, "nodes(ids:"):
+			c.Check(request.Variables["ids"], DeepEquals, []interface{}{"U_11", "U_12"})
+			fixture("graphql/users.json")(w, r)
+		default:
+			c.Errorf("unexpected query: %s", request.Query)
+		}
+	})
+	mux.HandleFunc("/avatars/jdoe.png", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write([]byte(pngSignature + "not really a png"))
+	})
+	s.requests = sync.Map{}
+	s.failLanguages = false
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, _ := s.requests.LoadOrStore(r.URL.Path, new(atomic.Int32))
+		n.(*atomic.Int32).Add(1)
+		mux.ServeHTTP(w, r)
+	}))
+}
+
+func (s *githubClientSuite) TearDownTest(c *C) {
+	s.server.Close()
+}
+
+func (s *githubClientSuite) organizationOf(c *C, api string) organization {
+	instance, err := clientFactories["github"](newAssetClient(http.DefaultTransport), http.DefaultTransport, sourceConfiguration{
+		Type:         "github",
+		Organization: "acme",
+		BaseUrl:      s.server.URL + "/api/v3/",
+		Api:          api,
+		TokenEnv:     "GITHUB_CLIENT_SUITE_TOKEN",
+	})
+	c.Assert(err, IsNil)
+
+	actual, err := instance.retrieveOrganization()
+	c.Assert(err, IsNil)
+	return actual
+}
+
+func (s *githubClientSuite) retrieve(c *C, api string) organization {
+	actual := s.organizationOf(c, api)
+	for i := range actual.Projects {
+		c.Assert(actual.Projects[i].rootCommitResolver, NotNil)
+		actual.Projects[i].rootCommitResolver = nil
+		c.Assert(actual.Projects[i].fileFetcher, NotNil)
+		actual.Projects[i].fileFetcher = nil
+		c.Assert(actual.Projects[i].releasesLister, NotNil)
+		actual.Projects[i].releasesLister = nil
+		c.Assert(actual.Projects[i].tagsLister, NotNil)
+		actual.Projects[i].tagsLister = nil
+		c.Assert(actual.Projects[i].contributorsLister, NotNil)
+		actual.Projects[i].contributorsLister = nil
+	}
+	return actual
+}
+
+func (s *githubClientSuite) TestRestAndGraphqlProduceTheSameOrganization(c *C) {
+	c.Assert(os.Setenv("GITHUB_CLIENT_SUITE_TOKEN", "secret"), IsNil)
+	defer func() { _ = os.Unsetenv("GITHUB_CLIENT_SUITE_TOKEN") }()
+
+	rest := s.retrieve(c, "rest")
+	graphql := s.retrieve(c, "graphql")
+
+	c.Assert(rest.Projects, HasLen, 2)
+	c.Assert(rest.Members, HasLen, 2)
+	c.Assert(graphql, DeepEquals, rest)
+
+	foo := graphql.Projects[0]
+	c.Assert(foo.Fullname, Equals, "acme/foo")
+	c.Assert(*foo.NumberOfOpenIssues, Equals, uint32(4))
+	c.Assert(*foo.NumberOfWatchers, Equals, uint32(5))
+	c.Assert(*foo.HttpCloneUrl, Equals, s.server.URL+"/acme/foo.git")
+	c.Assert(foo.Topics, DeepEquals, []string{"go", "logging"})
+	c.Assert(*foo.Language, Equals, "Golang")
+	c.Assert(foo.Languages, DeepEquals, map[string]float64{"Golang": 75, "Shell": 25})
+	c.Assert(*foo.License, DeepEquals, license{SpdxId: "MIT", Name: "MIT License", Url: "https://spdx.org/licenses/MIT.html"})
+	bar := graphql.Projects[1]
+	c.Assert(bar.mirrorOf, Equals, "https://gitlab.com/acme/bar.git")
+	c.Assert(*bar.HomepageUrl, Equals, s.server.URL+"/acme/bar")
+	c.Assert(bar.License, IsNil)
+	c.Assert(bar.Languages, IsNil)
+
+	c.Assert(graphql.Members[0].Name, Equals, "jdoe")
+	c.Assert(graphql.Members[0].ImageAsset, Not(Equals), "")
+	c.Assert(graphql.Members[1].Fullname, Equals, "jane")
+	c.Assert(graphql.Members[1].Email, IsNil)
+}
+
+func (s *githubClientSuite) TestKeepsDominantLanguageIfLanguagesFail(c *C) {
+	s.failLanguages = true
+
+	actual := s.organizationOf(c, "rest")
+
+	c.Assert(actual.Projects, HasLen, 2)
+	c.Assert(actual.Projects[0].Fullname, Equals, "acme/foo")
+	c.Assert(*actual.Projects[0].Language, Equals, "Golang")
+	c.Assert(actual.Projects[0].Languages, IsNil)
+}
+
+func (s *githubClientSuite) TestClassifiesLicenseFileIfNotReported(c *C) {
+	instance, err := clientFactories["github"](newAssetClient(http.DefaultTransport), http.DefaultTransport, sourceConfiguration{
+		Type:         "github",
+		Organization: "acme",
+		BaseUrl:      s.server.URL + "/api/v3/",
+	})
+	c.Assert(err, IsNil)
+	org, err := instance.retrieveOrganization()
+	c.Assert(err, IsNil)
+
+	actual := resolveLicenses(org.Projects)
+
+	c.Assert(actual[0].License.SpdxId, Equals, "MIT")
+	c.Assert(actual[1].Fullname, Equals, "acme/bar")
+	c.Assert(actual[1].License, NotNil)
+	c.Assert(actual[1].License.SpdxId, Equals, "Apache-2.0")
+}
+
+func (s *githubClientSuite) TestResolvesReleasesAndFallsBackToTags(c *C) {
+	c.Assert(os.Setenv("GITHUB_CLIENT_SUITE_TOKEN", "secret"), IsNil)
+	defer func() { _ = os.Unsetenv("GITHUB_CLIENT_SUITE_TOKEN") }()
+
+	for _, api := range []string{"rest", "graphql"} {
+		actual := resolveReleases(s.organizationOf(c, api).Projects, nil)
+
+		c.Assert(*actual[0].LatestRelease, DeepEquals, release{
+			Tag:         "v1.2.0",
+			Name:        "1.2.0",
+			PublishedAt: pTime(time.Date(2023, time.February, 1, 10, 0, 0, 0, time.UTC)),
+			Url:         s.server.URL + "/acme/foo/releases/tag/v1.2.0",
+		}, Commentf("%s", api))
+		c.Assert(*actual[1].LatestRelease, DeepEquals, release{
+			Tag:         "v0.10.0",
+			Name:        "v0.10.0",
+			PublishedAt: pTime(time.Date(2022, time.May, 1, 10, 0, 0, 0, time.UTC)),
+			Url:         s.server.URL + "/acme/bar/releases/tag/v0.10.0",
+		}, Commentf("%s", api))
+		// The prerelease 1.0.0-beta.1 is recent.
+		c.Assert(*actual[1].NumberOfRecentReleases, Equals, uint32(1), Commentf("%s", api))
+		c.Assert(s.requestsOf("/api/v3/repos/acme/bar/tags"), Equals, 1, Commentf("%s", api))
+	}
+	// GraphQL queries them together with the repositories.
+	c.Assert(s.requestsOf("/api/v3/repos/acme/foo/releases"), Equals, 1)
+	c.Assert(s.requestsOf("/api/v3/repos/acme/bar/commits/c1"), Equals, 1)
+}
+
+func (s *githubClientSuite) requestsOf(path string) int {
+	if n, ok := s.requests.Load(path); ok {
+		return int(n.(*atomic.Int32).Load())
+	}
+	return 0
+}
+
+func (s *githubClientSuite) TestResolvesContributorsWithoutBots(c *C) {
+	instance, err := clientFactories["github"](newAssetClient(http.DefaultTransport), http.DefaultTransport, sourceConfiguration{
+		Type:         "github",
+		Organization: "acme",
+		BaseUrl:      s.server.URL + "/api/v3/",
+	})
+	c.Assert(err, IsNil)
+	org, err := instance.retrieveOrganization()
+	c.Assert(err, IsNil)
+
+	org.Projects = contributors{}.resolve(org.Projects, identities{})
+	org.align()
+
+	foo := org.Projects[0]
+	c.Assert(foo.Contributors, HasLen, 2)
+	c.Assert(foo.Contributors[0].Identity, Equals, "github:jdoe")
+	c.Assert(foo.Contributors[0].ProfileUrl, Equals, s.server.URL+"/jdoe")
+	c.Assert(foo.Contributors[0].NumberOfCommits, Equals, uint32(42))
+	c.Assert(foo.Contributors[1].Login, Equals, "jane")
+	c.Assert(*foo.NumberOfContributors, Equals, uint32(2))
+	c.Assert(org.Projects[1].Contributors, HasLen, 0)
+	c.Assert(org.Statistics.NumberOfContributors, Equals, uint32(2))
+}
+
+func (s *githubClientSuite) TestGraphqlRequiresAccessToken(c *C) {
+	_, err := clientFactories["github"](nil, http.DefaultTransport, sourceConfiguration{
+		Type:         "github",
+		Organization: "acme",
+		Api:          "graphql",
+		TokenEnv:     "GITHUB_CLIENT_SUITE_DOES_NOT_EXIST",
+	})
+	c.Assert(err, ErrorMatches, ".*requires an accessToken.*")
+}
+
+func (s *githubClientSuite) TestGraphqlUrl(c *C) {
+	c.Assert((&githubClient{}).graphqlUrl(), Equals, "https://api.github.com/graphql")
+	c.Assert((&githubClient{baseUrl: "https://github.example.org/api/v3/"}).graphqlUrl(), Equals, "https://github.example.org/api/graphql")
+	c.Assert((&githubClient{baseUrl: "https://github.example.org"}).graphqlUrl(), Equals, "https://github.example.org/api/graphql")
+}
@@ -0,0 +1,491 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v50/github"
+)
+
+// githubGraphqlRetrieveTask retrieves the same projects and members as
+// githubClientRetrieveTask, but with a few paginated GraphQL queries instead
+// of one REST call per repository and member. The public members are still
+// listed with REST because GraphQL has no filter for public membership; the
+// same applies to the contributors of a repository, which GraphQL does not
+// report at all.
+type githubGraphqlRetrieveTask struct {
+	*githubClientRetrieveTask
+
+	graphql *graphqlClient
+}
+
+// githubGraphqlMaximumPageSize is the maximum number of nodes GitHub allows
+// per connection and per nodes() query.
+const githubGraphqlMaximumPageSize = 100
+
+const githubGraphqlRepositoriesQuery = `query($login: String!, $first: Int!, $after: String) {
+  organization(login: $login) {
+    repositories(first: $first, after: $after, privacy: PUBLIC, orderBy: {field: NAME, direction: ASC}) {
+      pageInfo { hasNextPage endCursor }
+      nodes {
+        name
+        nameWithOwner
+        owner { login }
+        description
+        url
+        homepageUrl
+        sshUrl
+        defaultBranchRef { name }
+        primaryLanguage { name }
+        languages(first: 100) { edges { size node { name } } }
+        hasIssuesEnabled
+        hasWikiEnabled
+        forkCount
+        stargazerCount
+        issues(states: OPEN) { totalCount }
+        pullRequests(states: OPEN) { totalCount }
+        createdAt
+        pushedAt
+        repositoryTopics(first: 100) { nodes { topic { name } } }
+        isFork
+        isArchived
+        mirrorUrl
+        licenseInfo { spdxId name }
+        releases(first: 100, orderBy: {field: CREATED_AT, direction: DESC}) {
+          nodes { tagName name publishedAt url isPrerelease isDraft }
+        }
+        refs(refPrefix: "refs/tags/", first: 100, orderBy: {field: TAG_COMMIT_DATE, direction: DESC}) {
+          nodes {
+            name
+            target {
+              ... on Commit { committedDate }
+              ... on Tag { target { ... on Commit { committedDate } } }
+            }
+          }
+        }
+      }
+    }
+  }
+}`
+
+const githubGraphqlUsersQuery = `query($ids: [ID!]!) {
+  nodes(ids: $ids) {
+    ... on User {
+      login
+      name
+      email
+      avatarUrl
+      url
+      bio
+      location
+      company
+      websiteUrl
+      createdAt
+      updatedAt
+    }
+  }
+}`
+
+type githubGraphqlPageInfo struct {
+	HasNextPage bool   `json:"hasNextPage"`
+	EndCursor   string `json:"endCursor"`
+}
+
+type githubGraphqlName struct {
+	Name string `json:"name"`
+}
+
+type githubGraphqlCount struct {
+	TotalCount uint32 `json:"totalCount"`
+}
+
+type githubGraphqlRepository struct {
+	Name          string `json:"name"`
+	NameWithOwner string `json:"nameWithOwner"`
+	Owner         struct {
+		Login string `json:"login"`
+	} `json:"owner"`
+	Description      *string            `json:"description"`
+	Url              string             `json:"url"`
+	HomepageUrl      *string            `json:"homepageUrl"`
+	SshUrl           string             `json:"sshUrl"`
+	DefaultBranchRef *githubGraphqlName `json:"defaultBranchRef"`
+	PrimaryLanguage  *githubGraphqlName `json:"primaryLanguage"`
+	Languages        struct {
+		Edges []struct {
+			Size int               `json:"size"`
+			Node githubGraphqlName `json:"node"`
+		} `json:"edges"`
+	} `json:"languages"`
+	HasIssuesEnabled bool               `json:"hasIssuesEnabled"`
+	HasWikiEnabled   bool               `json:"hasWikiEnabled"`
+	ForkCount        uint32             `json:"forkCount"`
+	StargazerCount   uint32             `json:"stargazerCount"`
+	Issues           githubGraphqlCount `json:"issues"`
+	PullRequests     githubGraphqlCount `json:"pullRequests"`
+	CreatedAt        time.Time          `json:"createdAt"`
+	PushedAt         *time.Time         `json:"pushedAt"`
+	RepositoryTopics struct {
+		Nodes []struct {
+			Topic githubGraphqlName `json:"topic"`
+		} `json:"nodes"`
+	} `json:"repositoryTopics"`
+	IsFork      bool    `json:"isFork"`
+	IsArchived  bool    `json:"isArchived"`
+	MirrorUrl   *string `json:"mirrorUrl"`
+	LicenseInfo *struct {
+		SpdxId *string `json:"spdxId"`
+		Name   string  `json:"name"`
+	} `json:"licenseInfo"`
+	Releases struct {
+		Nodes []githubGraphqlRelease `json:"nodes"`
+	} `json:"releases"`
+	Refs struct {
+		Nodes []githubGraphqlTag `json:"nodes"`
+	} `json:"refs"`
+}
+
+type githubGraphqlRelease struct {
+	TagName      string     `json:"tagName"`
+	Name         *string    `json:"name"`
+	PublishedAt  *time.Time `json:"publishedAt"`
+	Url          string     `json:"url"`
+	IsPrerelease bool       `json:"isPrerelease"`
+	IsDraft      bool       `json:"isDraft"`
+}
+
+type githubGraphqlCommit struct {
+	CommittedDate *time.Time `json:"committedDate"`
+}
+
+type githubGraphqlTag struct {
+	Name   string `json:"name"`
+	Target struct {
+		githubGraphqlCommit
+		// Target is the commit of annotated tags.
+		Target *githubGraphqlCommit `json:"target"`
+	} `json:"target"`
+}
+
+type githubGraphqlUser struct {
+	Login      string    `json:"login"`
+	Name       *string   `json:"name"`
+	Email      string    `json:"email"`
+	AvatarUrl  string    `json:"avatarUrl"`
+	Url        string    `json:"url"`
+	Bio        *string   `json:"bio"`
+	Location   *string   `json:"location"`
+	Company    *string   `json:"company"`
+	WebsiteUrl *string   `json:"websiteUrl"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+func (instance *githubClient) newGraphqlClient() *graphqlClient {
+	headers := http.Header{}
+	headers.Set("Authorization", "bearer "+instance.accessToken)
+	return newGraphqlClient(instance.graphqlUrl(), headers, instance.transport)
+}
+
+// graphqlUrl returns the GraphQL endpoint which is https://api.github.com/graphql
+// for github.com and <host>/api/graphql for GitHub Enterprise.
+func (instance *githubClient) graphqlUrl() string {
+	if instance.baseUrl == "" {
+		return "https://api.github.com/graphql"
+	}
+	baseUrl := strings.TrimSuffix(instance.baseUrl, "/")
+	baseUrl = strings.TrimSuffix(baseUrl, "/v3")
+	baseUrl = strings.TrimSuffix(baseUrl, "/api")
+	return baseUrl + "/api/graphql"
+}
+
+func (instance *githubGraphqlRetrieveTask) execute() (organization, error) {
+	if projects, err := instance.retrieveProjects(); err != nil {
+		return organization{}, err
+	} else if members, err := instance.retrieveMembers(); err != nil {
+		return organization{}, err
+	} else {
+		result := organization{
+			Projects: projects,
+			Members:  members,
+		}
+		result.align()
+		return result, nil
+	}
+}
+
+func (instance *githubGraphqlRetrieveTask) pageSize() int {
+	if instance.entriesPerPage <= 0 || instance.entriesPerPage > githubGraphqlMaximumPageSize {
+		return githubGraphqlMaximumPageSize
+	}
+	return instance.entriesPerPage
+}
+
+func (instance *githubGraphqlRetrieveTask) retrieveProjects() ([]project, error) {
+	var result []project
+	variables := map[string]interface{}{
+		"login": instance.organization,
+		"first": instance.pageSize(),
+	}
+	for i := 1; instance.maximumNumberOfEntries < 0 || i < instance.maximumNumberOfEntries; {
+		var data struct {
+			Organization *struct {
+				Repositories struct {
+					PageInfo githubGraphqlPageInfo     `json:"pageInfo"`
+					Nodes    []githubGraphqlRepository `json:"nodes"`
+				} `json:"repositories"`
+			} `json:"organization"`
+		}
+		if err := instance.graphql.query(instance.ctx, githubGraphqlRepositoriesQuery, variables, &data); err != nil {
+			return nil, fmt.Errorf("cannot search for repositories: %w", err)
+		}
+		if data.Organization == nil {
+			return nil, fmt.Errorf("organization '%s' does not exist", instance.organization)
+		}
+		for _, repo := range data.Organization.Repositories.Nodes {
+			if instance.maximumNumberOfEntries > 0 && i > instance.maximumNumberOfEntries {
+				break
+			}
+			result = append(result, instance.repoToProject(repo))
+			i++
+		}
+
+		if !data.Organization.Repositories.PageInfo.HasNextPage {
+			break
+		}
+		variables["after"] = data.Organization.Repositories.PageInfo.EndCursor
+	}
+	return result, nil
+}
+
+func (instance *githubGraphqlRetrieveTask) repoToProject(repo githubGraphqlRepository) project {
+	name := repo.Name
+	fullname := repo.NameWithOwner
+	if len(fullname) == 0 {
+		fullname = name
+	}
+	homepage := stringOf(repo.HomepageUrl)
+	if len(homepage) == 0 {
+		homepage = repo.Url
+	}
+	issuesUrl := ""
+	if repo.HasIssuesEnabled {
+		issuesUrl = repo.Url + "/issues"
+	}
+	wikiUrl := ""
+	if repo.HasWikiEnabled {
+		wikiUrl = repo.Url + "/wiki"
+	}
+	pullRequestsUrl := repo.Url + "/pulls"
+	forksUrl := repo.Url + "/network"
+	createForkUrl := repo.Url + "/fork"
+	starsUrl := repo.Url + "/stargazers"
+	watchersUrl := repo.Url + "/watchers"
+
+	defaultBranch := ""
+	if repo.DefaultBranchRef != nil {
+		defaultBranch = repo.DefaultBranchRef.Name
+	}
+	language := ""
+	if repo.PrimaryLanguage != nil {
+		language = canonicalLanguage(repo.PrimaryLanguage.Name)
+	}
+	bytesPerLanguage := make(map[string]int, len(repo.Languages.Edges))
+	for _, edge := range repo.Languages.Edges {
+		bytesPerLanguage[edge.Node.Name] = edge.Size
+	}
+	// Like the REST API there are no topics but an empty list of them.
+	topics := make([]string, 0, len(repo.RepositoryTopics.Nodes))
+	for _, node := range repo.RepositoryTopics.Nodes {
+		topics = append(topics, node.Topic.Name)
+	}
+	var updatedAt time.Time
+	if repo.PushedAt != nil {
+		updatedAt = *repo.PushedAt
+	}
+	var license *license
+	if repo.LicenseInfo != nil {
+		license = licenseOf(stringOf(repo.LicenseInfo.SpdxId), repo.LicenseInfo.Name)
+	}
+
+	return project{
+		Type:            "repository:git:github",
+		Origin:          "github",
+		Fullname:        fullname,
+		Name:            name,
+		Description:     pString(stringOf(repo.Description)),
+		DefaultBranch:   pString(defaultBranch),
+		Language:        pString(language),
+		Languages:       languagesOfBytes(bytesPerLanguage),
+		HomepageUrl:     &homepage,
+		ProfileUrl:      repo.Url,
+		HttpCloneUrl:    pString(repo.Url + ".git"),
+		SshCloneUrl:     pString(repo.SshUrl),
+		IssuesUrl:       pNonEmptyString(issuesUrl),
+		WikiUrl:         pNonEmptyString(wikiUrl),
+		ForksUrl:        &forksUrl,
+		PullRequestsUrl: &pullRequestsUrl,
+		CreateForkUrl:   &createForkUrl,
+		StarsUrl:        &starsUrl,
+		WatchersUrl:     &watchersUrl,
+		NumberOfForks:   pUint32(repo.ForkCount),
+		// Like the REST API this counts open pull requests as issues, too.
+		NumberOfOpenIssues: pUint32(repo.Issues.TotalCount + repo.PullRequests.TotalCount),
+		NumberOfStars:      pUint32(repo.StargazerCount),
+		// The REST API reports the stargazers as watchers, too.
+		NumberOfWatchers: pUint32(repo.StargazerCount),
+		CreatedAt:        pTime(repo.CreatedAt),
+		UpdatedAt:        pTime(updatedAt),
+		Topics:           topics,
+		License:          license,
+		Fork:             repo.IsFork,
+		Archived:         repo.IsArchived,
+		mirrorOf:         stringOf(repo.MirrorUrl),
+		rootCommitResolver: instance.rootCommitResolverOf(github.Repository{
+			Owner:         &github.User{Login: &repo.Owner.Login},
+			Name:          &repo.Name,
+			DefaultBranch: &defaultBranch,
+		}),
+		fileFetcher:        instance.fileFetcherOf(repo.Owner.Login, repo.Name, defaultBranch),
+		releasesLister:     repo.releasesLister(),
+		tagsLister:         repo.tagsLister(),
+		contributorsLister: instance.contributorsListerOf(repo.Owner.Login, repo.Name),
+	}
+}
+
+// releasesLister returns the releases which were already queried with the
+// repository.
+func (instance githubGraphqlRepository) releasesLister() releasesLister {
+	result := make([]release, 0, len(instance.Releases.Nodes))
+	for _, candidate := range instance.Releases.Nodes {
+		if candidate.IsDraft {
+			continue
+		}
+		name := stringOf(candidate.Name)
+		if name == "" {
+			name = candidate.TagName
+		}
+		result = append(result, release{
+			Tag:         candidate.TagName,
+			Name:        name,
+			PublishedAt: candidate.PublishedAt,
+			Url:         candidate.Url,
+			Prerelease:  candidate.IsPrerelease,
+		})
+	}
+	return func() ([]release, error) {
+		return result, nil
+	}
+}
+
+// tagsLister returns the tags which were already queried with the
+// repository, dated by their commit.
+func (instance githubGraphqlRepository) tagsLister() releasesLister {
+	result := make([]release, len(instance.Refs.Nodes))
+	for i, tag := range instance.Refs.Nodes {
+		publishedAt := tag.Target.CommittedDate
+		if tag.Target.Target != nil {
+			publishedAt = tag.Target.Target.CommittedDate
+		}
+		result[i] = release{
+			Tag:         tag.Name,
+			Name:        tag.Name,
+			PublishedAt: publishedAt,
+			Url:         instance.Url + "/releases/tag/" + url.PathEscape(tag.Name),
+		}
+	}
+	return func() ([]release, error) {
+		return result, nil
+	}
+}
+
+func (instance *githubGraphqlRetrieveTask) retrieveMembers() ([]member, error) {
+	var ids []string
+	opt := &github.ListMembersOptions{
+		ListOptions: github.ListOptions{PerPage: instance.entriesPerPage},
+		PublicOnly:  true,
+	}
+	for i := 1; instance.maximumNumberOfEntries < 0 || i < instance.maximumNumberOfEntries; {
+		users, resp, err := instance.client.Organizations.ListMembers(instance.ctx, instance.organization, opt)
+		if err != nil {
+			return nil, fmt.Errorf("cannot search for users: %v", err)
+		}
+		for _, user := range users {
+			if instance.maximumNumberOfEntries > 0 && i > instance.maximumNumberOfEntries {
+				break
+			}
+			ids = append(ids, user.GetNodeID())
+			i++
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+
+	var result []member
+	for len(ids) > 0 {
+		batch := ids[:min(len(ids), githubGraphqlMaximumPageSize)]
+		ids = ids[len(batch):]
+
+		var data struct {
+			Nodes []*githubGraphqlUser `json:"nodes"`
+		}
+		if err := instance.graphql.query(instance.ctx, githubGraphqlUsersQuery, map[string]interface{}{"ids": batch}, &data); err != nil {
+			return nil, fmt.Errorf("cannot get details of users: %w", err)
+		}
+		for i, user := range data.Nodes {
+			if user == nil {
+				return nil, fmt.Errorf("cannot get details of user with node id '%s'", batch[i])
+			}
+			if member, err := instance.userToMember(*user); err != nil {
+				return nil, fmt.Errorf("cannot get details for user '%s': %w", user.Login, err)
+			} else {
+				result = append(result, member)
+			}
+		}
+	}
+	return result, nil
+}
+
+func (instance *githubGraphqlRetrieveTask) userToMember(user githubGraphqlUser) (member, error) {
+	name := user.Login
+	fullname := stringOf(user.Name)
+	if len(fullname) == 0 {
+		fullname = name
+	}
+	homepage := stringOf(user.WebsiteUrl)
+	if len(homepage) == 0 {
+		homepage = user.Url
+	}
+
+	imageAsset := ""
+	if user.AvatarUrl != "" {
+		r, err := instance.assetClient.retrieve(memberAssetOwner(name), user.AvatarUrl)
+		if err != nil {
+			return member{}, err
+		}
+		imageAsset = r
+	}
+
+	return member{
+		Type:        "user:github",
+		Fullname:    fullname,
+		Name:        name,
+		Email:       pNonEmptyString(user.Email),
+		ImageAsset:  imageAsset,
+		ProfileUrl:  user.Url,
+		Bio:         pString(stringOf(user.Bio)),
+		Location:    pString(stringOf(user.Location)),
+		Company:     pString(stringOf(user.Company)),
+		HomepageUrl: &homepage,
+		CreatedAt:   pTime(user.CreatedAt),
+		UpdatedAt:   pTime(user.UpdatedAt),
+		// GitHub only allows verified addresses as public email.
+		EmailVerified: true,
+	}, nil
+}
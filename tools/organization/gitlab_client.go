@@ -4,8 +4,13 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"net/http"
+	"net/url"
+	"time"
 
+	log "github.com/echocat/slf4g"
 	"github.com/xanzy/go-gitlab"
+	"golang.org/x/mod/semver"
 )
 
 var (
@@ -14,9 +19,20 @@ var (
 	gitlabAccessToken            = flag.String("gitlabAccessToken", "", "Gitlab accessToken to access the API.")
 )
 
+func init() {
+	clientFactories["gitlab"] = func(assetClient *assetClient, transport http.RoundTripper, source sourceConfiguration) (client, error) {
+		return newGitlabClient(assetClient, transport, source), nil
+	}
+}
+
 type gitlabClient struct {
-	accessToken string
-	assetClient *assetClient
+	group                  string
+	baseUrl                string
+	accessToken            string
+	entriesPerPage         int
+	maximumNumberOfEntries int
+	assetClient            *assetClient
+	transport              http.RoundTripper
 }
 
 type gitlabClientRetrieveTask struct {
@@ -26,10 +42,15 @@ type gitlabClientRetrieveTask struct {
 	ctx    context.Context
 }
 
-func newGitlabClient(assetClient *assetClient) *gitlabClient {
+func newGitlabClient(assetClient *assetClient, transport http.RoundTripper, source sourceConfiguration) *gitlabClient {
 	return &gitlabClient{
-		assetClient: assetClient,
-		accessToken: *gitlabAccessToken,
+		group:                  source.Organization,
+		baseUrl:                source.BaseUrl,
+		accessToken:            source.accessToken(*gitlabAccessToken),
+		entriesPerPage:         source.entriesPerPage(*gitlabEntriesPerPage),
+		maximumNumberOfEntries: source.maximumNumberOfEntries(*gitlabMaximumNumberOfEntries),
+		assetClient:            assetClient,
+		transport:              transport,
 	}
 }
 
@@ -66,24 +87,20 @@ func (instance *gitlabClientRetrieveTask) execute() (organization, error) {
 }
 
 func (instance *gitlabClientRetrieveTask) retrieveMembers() ([]member, error) {
-	var result []member
+	var candidates []*gitlab.GroupMember
 	opt := &gitlab.ListGroupMembersOptions{
-		ListOptions: gitlab.ListOptions{PerPage: *gitlabEntriesPerPage},
+		ListOptions: gitlab.ListOptions{PerPage: instance.entriesPerPage},
 	}
-	for i := 1; *gitlabMaximumNumberOfEntries < 0 || i < *gitlabMaximumNumberOfEntries; {
-		groupMembers, resp, err := instance.client.Groups.ListGroupMembers(3460920, opt)
+	for i := 1; instance.maximumNumberOfEntries < 0 || i < instance.maximumNumberOfEntries; {
+		groupMembers, resp, err := instance.client.Groups.ListGroupMembers(instance.group, opt)
 		if err != nil {
-			return result, fmt.Errorf("cannot search for group members: %v", err)
+			return nil, fmt.Errorf("cannot search for group members: %v", err)
 		}
 		for _, groupMember := range groupMembers {
-			if *gitlabMaximumNumberOfEntries > 0 && i > *gitlabMaximumNumberOfEntries {
+			if instance.maximumNumberOfEntries > 0 && i > instance.maximumNumberOfEntries {
 				break
 			}
-			if project, err := instance.groupMemberToMember(*groupMember); err != nil {
-				return nil, fmt.Errorf("cannot get details of group member '%s': %w", groupMember.Username, err)
-			} else {
-				result = append(result, project)
-			}
+			candidates = append(candidates, groupMember)
 			i++
 		}
 
@@ -92,7 +109,13 @@ func (instance *gitlabClientRetrieveTask) retrieveMembers() ([]member, error) {
 		}
 		opt.Page = resp.NextPage
 	}
-	return result, nil
+	return mapConcurrently(candidates, func(groupMember *gitlab.GroupMember) (member, error) {
+		if member, err := instance.groupMemberToMember(*groupMember); err != nil {
+			return member, fmt.Errorf("cannot get details of group member '%s': %w", groupMember.Username, err)
+		} else {
+			return member, nil
+		}
+	})
 }
 
 func (instance *gitlabClientRetrieveTask) detailsOfGroupMember(input gitlab.GroupMember) (gitlab.User, error) {
@@ -112,25 +135,32 @@ func (instance *gitlabClientRetrieveTask) groupMemberToMember(repo gitlab.GroupM
 		if len(fullname) == 0 {
 			fullname = name
 		}
-		profile := "https://gitlab.com/" + detailed.Username
+		profile := detailed.WebURL
+		if len(profile) == 0 {
+			profile = "https://gitlab.com/" + detailed.Username
+		}
 		homepage := detailed.WebsiteURL
 		if len(homepage) == 0 {
 			homepage = profile
 		}
 		imageAsset := ""
 		if detailed.AvatarURL != "" {
-			r, err := instance.assetClient.retrieve(detailed.AvatarURL)
+			r, err := instance.assetClient.retrieve(memberAssetOwner(name), detailed.AvatarURL)
 			if err != nil {
 				return member{}, err
 			}
 			imageAsset = r
 		}
+		email := detailed.PublicEmail
+		if len(email) == 0 {
+			email = detailed.Email
+		}
 
 		return member{
 			Type:        "user:gitlab",
 			Fullname:    fullname,
 			Name:        name,
-			Email:       pNonEmptyString(detailed.Email),
+			Email:       pNonEmptyString(email),
 			ImageAsset:  imageAsset,
 			ProfileUrl:  profile,
 			Bio:         pNonEmptyString(detailed.Bio),
@@ -141,33 +171,29 @@ func (instance *gitlabClientRetrieveTask) groupMemberToMember(repo gitlab.GroupM
 			LinkedinId:  pNonEmptyString(detailed.Linkedin),
 			HomepageUrl: &homepage,
 			CreatedAt:   detailed.CreatedAt,
+			// GitLab only allows verified addresses as public email.
+			EmailVerified: email == detailed.PublicEmail,
 		}, nil
 	}
 }
 
 func (instance *gitlabClientRetrieveTask) retrieveProjects() ([]project, error) {
-	var result []project
+	var candidates []*gitlab.Project
 	opt := &gitlab.ListGroupProjectsOptions{
-		ListOptions: gitlab.ListOptions{PerPage: *gitlabEntriesPerPage},
+		ListOptions: gitlab.ListOptions{PerPage: instance.entriesPerPage},
 		Visibility:  pGitlabVisibilityValue(gitlab.PublicVisibility),
 	}
-	for i := 1; *gitlabMaximumNumberOfEntries < 0 || i < *gitlabMaximumNumberOfEntries; {
-		groupProjects, resp, err := instance.client.Groups.ListGroupProjects(3460920, opt)
+	for i := 1; instance.maximumNumberOfEntries < 0 || i < instance.maximumNumberOfEntries; {
+		groupProjects, resp, err := instance.client.Groups.ListGroupProjects(instance.group, opt)
 		if err != nil {
-			return result, fmt.Errorf("cannot search for users: %v", err)
+			return nil, fmt.Errorf("cannot search for users: %v", err)
 		}
 		for _, groupProject := range groupProjects {
-			if *gitlabMaximumNumberOfEntries > 0 && i > *gitlabMaximumNumberOfEntries {
+			if instance.maximumNumberOfEntries > 0 && i > instance.maximumNumberOfEntries {
 				break
 			}
-			if !groupProject.Archived {
-				if project, err := instance.groupProjectToProject(*groupProject); err != nil {
-					return nil, fmt.Errorf("cannot get details of group project '%s': %w", groupProject.Name, err)
-				} else {
-					result = append(result, project)
-				}
-				i++
-			}
+			candidates = append(candidates, groupProject)
+			i++
 		}
 
 		if resp.NextPage == 0 {
@@ -175,12 +201,18 @@ func (instance *gitlabClientRetrieveTask) retrieveProjects() ([]project, error)
 		}
 		opt.Page = resp.NextPage
 	}
-	return result, nil
+	return mapConcurrently(candidates, func(groupProject *gitlab.Project) (project, error) {
+		if project, err := instance.groupProjectToProject(*groupProject); err != nil {
+			return project, fmt.Errorf("cannot get details of group project '%s': %w", groupProject.Name, err)
+		} else {
+			return project, nil
+		}
+	})
 }
 
 func (instance *gitlabClientRetrieveTask) detailsOfGroupProject(input gitlab.Project) (gitlab.Project, error) {
-	if result, _, err := instance.client.Projects.GetProject(input.ID, nil); err != nil {
-		return gitlab.Project{}, fmt.Errorf("cannot get details of GitLab repository echocat/%s(%d): %v", input.Name, input.ID, err)
+	if result, _, err := instance.client.Projects.GetProject(input.ID, &gitlab.GetProjectOptions{License: pBool(true)}); err != nil {
+		return gitlab.Project{}, fmt.Errorf("cannot get details of GitLab repository %s/%s(%d): %v", instance.group, input.Name, input.ID, err)
 	} else {
 		return *result, nil
 	}
@@ -188,25 +220,23 @@ func (instance *gitlabClientRetrieveTask) detailsOfGroupProject(input gitlab.Pro
 
 func (instance *gitlabClientRetrieveTask) languagesOfGroupProject(input gitlab.Project) (gitlab.ProjectLanguages, error) {
 	if result, _, err := instance.client.Projects.GetProjectLanguages(input.ID, nil); err != nil {
-		return gitlab.ProjectLanguages{}, fmt.Errorf("cannot get languages of GitLab repository echocat/%s(%d): %v", input.Name, input.ID, err)
+		return gitlab.ProjectLanguages{}, fmt.Errorf("cannot get languages of GitLab repository %s/%s(%d): %v", instance.group, input.Name, input.ID, err)
 	} else {
 		return *result, nil
 	}
 }
 
-func (instance *gitlabClientRetrieveTask) languageOfGroupProject(input gitlab.Project) (result string, err error) {
+// percentagesOfGroupProject takes the percentages of GitLab as they are.
+func (instance *gitlabClientRetrieveTask) percentagesOfGroupProject(input gitlab.Project) (map[string]float64, error) {
 	languages, err := instance.languagesOfGroupProject(input)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	var maxRating float32 = 0.0
-	for candidate, rating := range languages {
-		if rating > maxRating {
-			maxRating = rating
-			result = candidate
-		}
+	result := make(map[string]float64, len(languages))
+	for candidate, percentage := range languages {
+		result[candidate] = float64(percentage)
 	}
-	return
+	return languagesOfPercentages(result), nil
 }
 
 func (instance *gitlabClientRetrieveTask) groupProjectToProject(repo gitlab.Project) (project, error) {
@@ -214,15 +244,17 @@ func (instance *gitlabClientRetrieveTask) groupProjectToProject(repo gitlab.Proj
 	if err != nil {
 		return project{}, err
 	}
-	language, err := instance.languageOfGroupProject(repo)
-	if err != nil {
-		return project{}, err
-	}
 	name := detailed.Path
 	fullname := detailed.Name
 	if len(fullname) == 0 {
 		fullname = name
 	}
+	languages, err := instance.percentagesOfGroupProject(repo)
+	if err != nil {
+		log.WithError(err).
+			With("project", "gitlab:"+fullname).
+			Warn("Cannot get languages; the language of the project stays unknown.")
+	}
 	issuesUrl := ""
 	if detailed.IssuesEnabled {
 		issuesUrl = detailed.WebURL + "/issues"
@@ -241,13 +273,19 @@ func (instance *gitlabClientRetrieveTask) groupProjectToProject(repo gitlab.Proj
 
 	imageAsset := ""
 	if detailed.AvatarURL != "" {
-		r, err := instance.assetClient.retrieve(detailed.AvatarURL)
+		r, err := instance.assetClient.retrieve(projectAssetOwner("gitlab", fullname), detailed.AvatarURL)
 		if err != nil {
 			return project{}, err
 		}
 		imageAsset = r
 	}
 
+	topics := detailed.Topics
+	if len(topics) == 0 {
+		// Older GitLab versions only provide the deprecated tag list.
+		topics = detailed.TagList
+	}
+
 	return project{
 		Type:               "repository:git:gitlab",
 		Origin:             "gitlab",
@@ -255,7 +293,8 @@ func (instance *gitlabClientRetrieveTask) groupProjectToProject(repo gitlab.Proj
 		Name:               name,
 		Description:        pNonEmptyString(detailed.Description),
 		DefaultBranch:      pNonEmptyString(detailed.DefaultBranch),
-		Language:           pNonEmptyString(language),
+		Language:           pNonEmptyString(dominantLanguageOf(languages)),
+		Languages:          languages,
 		HomepageUrl:        pNonEmptyString(detailed.WebURL),
 		ImageAsset:         pNonEmptyString(imageAsset),
 		ProfileUrl:         detailed.WebURL,
@@ -272,9 +311,170 @@ func (instance *gitlabClientRetrieveTask) groupProjectToProject(repo gitlab.Proj
 		NumberOfStars:      pUint32(uint32(detailed.StarCount)),
 		CreatedAt:          pTime(*detailed.CreatedAt),
 		UpdatedAt:          pTime(*detailed.LastActivityAt),
+		Topics:             topics,
+		License:            instance.licenseOf(detailed),
+		Fork:               detailed.ForkedFromProject != nil,
+		Archived:           detailed.Archived,
+		mirrorOf:           instance.mirrorOf(detailed),
+		rootCommitResolver: instance.rootCommitResolverOf(detailed),
+		fileFetcher:        instance.fileFetcherOf(detailed),
+		releasesLister:     instance.releasesListerOf(detailed),
+		tagsLister:         instance.tagsListerOf(detailed),
+		contributorsLister: instance.contributorsListerOf(detailed),
 	}, nil
 }
 
+// licenseOf uses the license key of GitLab, which is the lowercase SPDX
+// identifier.
+func (instance *gitlabClientRetrieveTask) licenseOf(repo gitlab.Project) *license {
+	if repo.License == nil {
+		return nil
+	}
+	return licenseOf(repo.License.Key, repo.License.Name)
+}
+
+func (instance *gitlabClientRetrieveTask) fileFetcherOf(repo gitlab.Project) fileFetcher {
+	return func(file string) (string, bool, error) {
+		content, resp, err := instance.client.RepositoryFiles.GetRawFile(repo.ID, file, &gitlab.GetRawFileOptions{
+			Ref: pNonEmptyString(repo.DefaultBranch),
+		})
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return "", false, nil
+		}
+		if err != nil {
+			return "", false, fmt.Errorf("cannot get %s of GitLab repository %s/%s(%d): %v", file, instance.group, repo.Name, repo.ID, err)
+		}
+		return string(content), true, nil
+	}
+}
+
+// releasesListerOf skips upcoming releases, which are the drafts of GitLab.
+func (instance *gitlabClientRetrieveTask) releasesListerOf(repo gitlab.Project) releasesLister {
+	return func() ([]release, error) {
+		releases, _, err := instance.client.Releases.ListReleases(repo.ID, &gitlab.ListReleasesOptions{
+			ListOptions: gitlab.ListOptions{PerPage: 100},
+			OrderBy:     pString("released_at"),
+			Sort:        pString("desc"),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("cannot list releases of GitLab repository %s/%s(%d): %v", instance.group, repo.Name, repo.ID, err)
+		}
+		result := make([]release, 0, len(releases))
+		for _, candidate := range releases {
+			if candidate.UpcomingRelease {
+				continue
+			}
+			name := candidate.Name
+			if name == "" {
+				name = candidate.TagName
+			}
+			result = append(result, release{
+				Tag:         candidate.TagName,
+				Name:        name,
+				PublishedAt: candidate.ReleasedAt,
+				Url:         repo.WebURL + "/-/releases/" + url.PathEscape(candidate.TagName),
+				// GitLab has no prerelease flag; it is derived from the tag.
+				Prerelease: semver.Prerelease(semverOf(candidate.TagName)) != "",
+			})
+		}
+		return result, nil
+	}
+}
+
+// tagsListerOf uses the date of the tagged commit as date of the tag.
+func (instance *gitlabClientRetrieveTask) tagsListerOf(repo gitlab.Project) releasesLister {
+	return func() ([]release, error) {
+		tags, _, err := instance.client.Tags.ListTags(repo.ID, &gitlab.ListTagsOptions{
+			ListOptions: gitlab.ListOptions{PerPage: 100},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("cannot list tags of GitLab repository %s/%s(%d): %v", instance.group, repo.Name, repo.ID, err)
+		}
+		result := make([]release, len(tags))
+		for i, tag := range tags {
+			var publishedAt *time.Time
+			if tag.Commit != nil {
+				publishedAt = tag.Commit.CommittedDate
+			}
+			result[i] = release{
+				Tag:         tag.Name,
+				Name:        tag.Name,
+				PublishedAt: publishedAt,
+				Url:         repo.WebURL + "/-/tags/" + url.PathEscape(tag.Name),
+			}
+		}
+		return result, nil
+	}
+}
+
+// contributorsListerOf identifies contributors by their email, because GitLab
+// derives them from the commits and does not report their login.
+func (instance *gitlabClientRetrieveTask) contributorsListerOf(repo gitlab.Project) contributorsLister {
+	return func() ([]contributor, error) {
+		contributors, _, err := instance.client.Repositories.Contributors(repo.ID, &gitlab.ListContributorsOptions{
+			ListOptions: gitlab.ListOptions{PerPage: 100},
+			OrderBy:     pString("commits"),
+			Sort:        pString("desc"),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("cannot list contributors of GitLab repository %s/%s(%d): %v", instance.group, repo.Name, repo.ID, err)
+		}
+		result := make([]contributor, len(contributors))
+		for i, candidate := range contributors {
+			result[i] = contributor{
+				Origin:          "gitlab",
+				Name:            candidate.Name,
+				NumberOfCommits: uint32(candidate.Commits),
+				email:           candidate.Email,
+			}
+		}
+		return result, nil
+	}
+}
+
+func (instance *gitlabClientRetrieveTask) mirrorOf(repo gitlab.Project) string {
+	if !repo.Mirror {
+		return ""
+	}
+	return repo.ImportURL
+}
+
+func (instance *gitlabClientRetrieveTask) rootCommitResolverOf(repo gitlab.Project) func() (string, error) {
+	return func() (string, error) {
+		// The commits are listed newest first; with one commit per page the
+		// last page contains the root commit.
+		opt := &gitlab.ListCommitsOptions{
+			ListOptions: gitlab.ListOptions{PerPage: 1},
+			RefName:     pNonEmptyString(repo.DefaultBranch),
+		}
+		commits, resp, err := instance.client.Commits.ListCommits(repo.ID, opt)
+		if err != nil {
+			return "", fmt.Errorf("cannot list commits of GitLab repository %s/%s(%d): %v", instance.group, repo.Name, repo.ID, err)
+		}
+		if resp.TotalPages == 0 && resp.NextPage != 0 {
+			// GitLab omits the total for large repositories (more than
+			// 10,000 commits), so the last page is unknown.
+			return "", nil
+		}
+		if resp.TotalPages > 1 {
+			opt.Page = resp.TotalPages
+			if commits, _, err = instance.client.Commits.ListCommits(repo.ID, opt); err != nil {
+				return "", fmt.Errorf("cannot list commits of GitLab repository %s/%s(%d): %v", instance.group, repo.Name, repo.ID, err)
+			}
+		}
+		if len(commits) == 0 {
+			return "", nil
+		}
+		return commits[0].ID, nil
+	}
+}
+
 func (instance *gitlabClient) newClient(_ context.Context) (*gitlab.Client, error) {
-	return gitlab.NewClient(instance.accessToken)
+	opts := []gitlab.ClientOptionFunc{
+		gitlab.WithHTTPClient(&http.Client{Transport: instance.transport}),
+	}
+	if instance.baseUrl != "" {
+		opts = append(opts, gitlab.WithBaseURL(instance.baseUrl))
+	}
+	return gitlab.NewClient(instance.accessToken, opts...)
 }
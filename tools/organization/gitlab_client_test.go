@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/xanzy/go-gitlab"
+	. "gopkg.in/check.v1"
+)
+
+type gitlabClientSuite struct {
+	server *httptest.Server
+}
+
+var _ = Suite(&gitlabClientSuite{})
+
+func (s *gitlabClientSuite) SetUpTest(c *C) {
+	*assetsFolder = c.MkDir()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v4/projects/1/repository/commits", func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Query().Get("ref_name"), Equals, "main")
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Total-Pages", "3")
+		switch r.URL.Query().Get("page") {
+		case "", "1":
+			w.Header().Set("X-Next-Page", "2")
+			_, _ = w.Write([]byte(`[{"id": "newest"}]`))
+		case "3":
+			_, _ = w.Write([]byte(`[{"id": "root"}]`))
+		default:
+			c.Errorf("unexpected page %s", r.URL.Query().Get("page"))
+		}
+	})
+	// GitLab omits X-Total-Pages for more than 10,000 commits.
+	mux.HandleFunc("/api/v4/projects/2/repository/commits", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Next-Page", "2")
+		_, _ = w.Write([]byte(`[{"id": "newest"}]`))
+	})
+	mux.HandleFunc("/api/v4/projects/1", func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Query().Get("license"), Equals, "true")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id": 1, "path": "foo", "name": "Foo", "web_url": "` + s.server.URL + `/acme/foo", "default_branch": "main",
+			"license": {"key": "apache-2.0", "name": "Apache License 2.0"},
+			"created_at": "2020-01-02T03:04:05Z", "last_activity_at": "2021-01-02T03:04:05Z"}`))
+	})
+	mux.HandleFunc("/api/v4/projects/1/languages", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"Go": 60.25, "Shell": 20}`))
+	})
+	mux.HandleFunc("/api/v4/projects/1/releases", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`[
+			{"tag_name": "v2.0.0", "name": "Two", "released_at": "2099-01-01T00:00:00Z", "upcoming_release": true},
+			{"tag_name": "v1.1.0-rc.1", "released_at": "2024-02-01T00:00:00Z"},
+			{"tag_name": "v1.0.0", "name": "One", "released_at": "2024-01-01T00:00:00Z"}
+		]`))
+	})
+	mux.HandleFunc("/api/v4/projects/1/repository/contributors", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`[
+			{"name": "Jane", "email": "jane@example.org", "commits": 3},
+			{"name": "John Doe", "email": "JDoe@example.org", "commits": 7}
+		]`))
+	})
+	mux.HandleFunc("/api/v4/projects/2/releases", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`[]`))
+	})
+	mux.HandleFunc("/api/v4/projects/2/repository/tags", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`[
+			{"name": "latest", "commit": {"committed_date": "2024-03-01T00:00:00Z"}},
+			{"name": "v1.0.0-beta.1", "commit": {"committed_date": "2024-02-01T00:00:00Z"}},
+			{"name": "v0.9.0", "commit": {"committed_date": "2023-01-01T00:00:00Z"}}
+		]`))
+	})
+	mux.HandleFunc("/api/v4/projects/3", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id": 3, "path": "broken", "name": "broken", "web_url": "` + s.server.URL + `/acme/broken",
+			"created_at": "2020-01-02T03:04:05Z", "last_activity_at": "2021-01-02T03:04:05Z"}`))
+	})
+	mux.HandleFunc("/api/v4/projects/3/languages", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	})
+	s.server = httptest.NewServer(mux)
+}
+
+func (s *gitlabClientSuite) TearDownTest(_ *C) {
+	s.server.Close()
+}
+
+func (s *gitlabClientSuite) task(c *C) *gitlabClientRetrieveTask {
+	instance := newGitlabClient(newAssetClient(http.DefaultTransport), http.DefaultTransport, sourceConfiguration{
+		Type:         "gitlab",
+		Organization: "acme",
+		BaseUrl:      s.server.URL,
+	})
+	ctx := context.Background()
+	client, err := instance.newClient(ctx)
+	c.Assert(err, IsNil)
+	return &gitlabClientRetrieveTask{gitlabClient: instance, client: client, ctx: ctx}
+}
+
+func (s *gitlabClientSuite) TestResolvesRootCommitFromLastPage(c *C) {
+	actual, err := s.task(c).rootCommitResolverOf(gitlab.Project{ID: 1, DefaultBranch: "main"})()
+	c.Assert(err, IsNil)
+	c.Assert(actual, Equals, "root")
+}
+
+func (s *gitlabClientSuite) TestRootCommitIsUnknownWithoutTotalPages(c *C) {
+	actual, err := s.task(c).rootCommitResolverOf(gitlab.Project{ID: 2, DefaultBranch: "main"})()
+	c.Assert(err, IsNil)
+	c.Assert(actual, Equals, "")
+}
+
+func (s *gitlabClientSuite) TestKeepsProjectIfLanguagesFail(c *C) {
+	actual, err := s.task(c).groupProjectToProject(gitlab.Project{ID: 3})
+	c.Assert(err, IsNil)
+	c.Assert(actual.Name, Equals, "broken")
+	c.Assert(actual.Language, IsNil)
+	c.Assert(actual.Languages, HasLen, 0)
+}
+
+func (s *gitlabClientSuite) TestMapsLicenseKeys(c *C) {
+	task := s.task(c)
+	actual, err := task.groupProjectToProject(gitlab.Project{ID: 1})
+	c.Assert(err, IsNil)
+	c.Assert(actual.License, DeepEquals, &license{
+		SpdxId: "Apache-2.0",
+		Name:   "Apache License 2.0",
+		Url:    "https://spdx.org/licenses/Apache-2.0.html",
+	})
+
+	c.Assert(task.licenseOf(gitlab.Project{License: &gitlab.ProjectLicense{Key: "other", Name: "Other"}}), IsNil)
+	c.Assert(task.licenseOf(gitlab.Project{}), IsNil)
+}
+
+func (s *gitlabClientSuite) TestListsReleasesWithoutUpcomingOnes(c *C) {
+	task := s.task(c)
+	repo := gitlab.Project{ID: 1, WebURL: s.server.URL + "/acme/foo"}
+
+	actual, err := task.releasesListerOf(repo)()
+	c.Assert(err, IsNil)
+	c.Assert(actual, HasLen, 2)
+	c.Assert(actual[0].Name, Equals, "v1.1.0-rc.1")
+	c.Assert(actual[0].Prerelease, Equals, true)
+	c.Assert(actual[0].Url, Equals, s.server.URL+"/acme/foo/-/releases/v1.1.0-rc.1")
+	c.Assert(actual[1].Name, Equals, "One")
+	c.Assert(actual[1].Prerelease, Equals, false)
+
+	resolved := resolveReleases(projects{{releasesLister: task.releasesListerOf(repo)}}, nil)
+	c.Assert(resolved[0].LatestRelease.Tag, Equals, "v1.0.0")
+}
+
+func (s *gitlabClientSuite) TestFallsBackToTagsWithoutReleases(c *C) {
+	task := s.task(c)
+	repo := gitlab.Project{ID: 2, WebURL: s.server.URL + "/acme/bar"}
+
+	resolved := resolveReleases(projects{{
+		releasesLister: task.releasesListerOf(repo),
+		tagsLister:     task.tagsListerOf(repo),
+	}}, nil)
+	latest := resolved[0].LatestRelease
+	c.Assert(latest, NotNil)
+	c.Assert(latest.Tag, Equals, "v0.9.0")
+	c.Assert(latest.PublishedAt.Equal(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)), Equals, true)
+	c.Assert(latest.Url, Equals, s.server.URL+"/acme/bar/-/tags/v0.9.0")
+}
+
+func (s *gitlabClientSuite) TestTakesLanguagePercentagesAsTheyAre(c *C) {
+	actual, err := s.task(c).groupProjectToProject(gitlab.Project{ID: 1})
+	c.Assert(err, IsNil)
+	// GitLab omits minor languages; the rest is not scaled up to 100%.
+	c.Assert(actual.Languages, DeepEquals, map[string]float64{"Golang": 60.25, "Shell": 20})
+	c.Assert(*actual.Language, Equals, "Golang")
+}
+
+func (s *gitlabClientSuite) TestIdentifiesContributorsByTheirEmail(c *C) {
+	resolved := contributors{}.resolve(projects{{
+		Origin:             "gitlab",
+		contributorsLister: s.task(c).contributorsListerOf(gitlab.Project{ID: 1}),
+	}}, identities{Aliases: map[string][]string{"jdoe": {"gitlab:jdoe@example.org"}}})
+
+	actual := resolved[0].Contributors
+	c.Assert(actual, HasLen, 2)
+	c.Assert(actual[0].Name, Equals, "John Doe")
+	c.Assert(actual[0].NumberOfCommits, Equals, uint32(7))
+	c.Assert(actual[0].Identity, Equals, "jdoe")
+	c.Assert(actual[1].Name, Equals, "Jane")
+	c.Assert(actual[1].Identity, Matches, "gitlab:[0-9a-f]{16}")
+}
@@ -7,8 +7,11 @@ require (
 	github.com/echocat/slf4g/native v1.8.4
 	github.com/google/go-github/v50 v50.2.0
 	github.com/xanzy/go-gitlab v0.115.0
+	golang.org/x/image v0.33.0
+	golang.org/x/mod v0.31.0
 	golang.org/x/oauth2 v0.36.0
 	gopkg.in/check.v1 v1.0.0-20201130134442-10cb98267c6c
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
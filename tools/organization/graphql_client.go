@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// graphqlClient is a minimal GraphQL client which sends queries as JSON
+// POST requests to a single endpoint.
+type graphqlClient struct {
+	url     string
+	headers http.Header
+	client  *http.Client
+}
+
+type graphqlRequest struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables,omitempty"`
+}
+
+type graphqlResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []graphqlError  `json:"errors"`
+}
+
+type graphqlError struct {
+	Message string `json:"message"`
+}
+
+func newGraphqlClient(url string, headers http.Header, transport http.RoundTripper) *graphqlClient {
+	return &graphqlClient{
+		url:     url,
+		headers: headers,
+		client:  &http.Client{Transport: transport},
+	}
+}
+
+// query executes the given query with its variables and decodes the "data"
+// of the response into target.
+func (instance *graphqlClient) query(ctx context.Context, query string, variables map[string]interface{}, target interface{}) (err error) {
+	body, err := json.Marshal(graphqlRequest{Query: query, Variables: variables})
+	if err != nil {
+		return fmt.Errorf("cannot encode query for '%s': %w", instance.url, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, instance.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("cannot create request for '%s': %w", instance.url, err)
+	}
+	for key, values := range instance.headers {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := instance.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("cannot query '%s': %w", instance.url, err)
+	}
+	defer func() {
+		if cErr := resp.Body.Close(); cErr != nil && err == nil {
+			err = cErr
+		}
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status while querying '%s': %d - %s", instance.url, resp.StatusCode, resp.Status)
+	}
+
+	var result graphqlResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("cannot decode response of '%s': %w", instance.url, err)
+	}
+	if len(result.Errors) > 0 {
+		messages := make([]string, len(result.Errors))
+		for i, e := range result.Errors {
+			messages[i] = e.Message
+		}
+		return fmt.Errorf("query of '%s' failed: %s", instance.url, strings.Join(messages, "; "))
+	}
+	if err := json.Unmarshal(result.Data, target); err != nil {
+		return fmt.Errorf("cannot decode data of '%s': %w", instance.url, err)
+	}
+	return nil
+}
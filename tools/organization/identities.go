@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// identities link the accounts of the same person on different providers to
+// one member.
+type identities struct {
+	// Aliases maps the name of a person to the references of all of its
+	// accounts. A reference is "<origin>:<login>", for example "github:jdoe".
+	// Contributors without login are referenced by their email address, for
+	// example "gitlab:jdoe@example.org".
+	Aliases map[string][]string `yaml:"aliases"`
+	// MatchVerifiedEmails links accounts which share the same verified email
+	// address. Defaults to true.
+	MatchVerifiedEmails *bool `yaml:"matchVerifiedEmails"`
+}
+
+type memberAccount struct {
+	Type   string `json:"type"`
+	Origin string `json:"origin"`
+	// Source is the name of the source this account was retrieved from.
+	Source     string `json:"source"`
+	Login      string `json:"login"`
+	ProfileUrl string `json:"profileUrl"`
+	ImageAsset string `json:"imageAsset"`
+}
+
+func (instance memberAccount) reference() string {
+	return instance.Origin + ":" + instance.Login
+}
+
+// toMember re-expands this account of the given merged member into the
+// member it was before the accounts were linked. The profile of the merged
+// member is kept, so it is linked again by its alias or verified email.
+func (instance memberAccount) toMember(merged member) member {
+	result := merged
+	result.Type = instance.Type
+	result.Source = instance.Source
+	result.Name = instance.Login
+	result.ProfileUrl = instance.ProfileUrl
+	result.ImageAsset = instance.ImageAsset
+	result.ImageVariants = nil
+	result.Accounts = []memberAccount{instance}
+	return result
+}
+
+func (instance identities) validate() error {
+	seen := map[string]string{}
+	for name, references := range instance.Aliases {
+		for _, reference := range references {
+			if !strings.Contains(reference, ":") {
+				return fmt.Errorf("identities.aliases.%s contains '%s' which is not of format <origin>:<login>", name, reference)
+			}
+			if other, ok := seen[reference]; ok && other != name {
+				return fmt.Errorf("identities.aliases contains '%s' for both '%s' and '%s'", reference, other, name)
+			}
+			seen[reference] = name
+		}
+	}
+	return nil
+}
+
+func (instance identities) matchVerifiedEmails() bool {
+	return instance.MatchVerifiedEmails == nil || *instance.MatchVerifiedEmails
+}
+
+// aliasOf maps every reference to the name of its alias.
+func (instance identities) aliasOf() map[string]string {
+	result := map[string]string{}
+	for name, references := range instance.Aliases {
+		for _, reference := range references {
+			result[reference] = name
+		}
+	}
+	return result
+}
+
+// resolve merges all members which belong to the same person, either because
+// they are listed in the same alias or share a verified email address.
+func (instance identities) resolve(input members) members {
+	// Union-find over the indexes of input.
+	parents := make([]int, len(input))
+	for i := range parents {
+		parents[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parents[i] != i {
+			parents[i] = find(parents[i])
+		}
+		return parents[i]
+	}
+	union := func(a, b int) {
+		if ra, rb := find(a), find(b); ra != rb {
+			parents[rb] = ra
+		}
+	}
+
+	aliasOf := instance.aliasOf()
+
+	byAlias := map[string]int{}
+	byEmail := map[string]int{}
+	aliasOfMember := make([]string, len(input))
+	for i, candidate := range input {
+		for _, account := range candidate.accounts() {
+			if alias, ok := aliasOf[account.reference()]; ok {
+				aliasOfMember[i] = alias
+				if existing, ok := byAlias[alias]; ok {
+					union(existing, i)
+				} else {
+					byAlias[alias] = i
+				}
+			}
+		}
+		if instance.matchVerifiedEmails() && candidate.EmailVerified && candidate.Email != nil && *candidate.Email != "" {
+			email := strings.ToLower(*candidate.Email)
+			if existing, ok := byEmail[email]; ok {
+				union(existing, i)
+			} else {
+				byEmail[email] = i
+			}
+		}
+	}
+
+	groups := map[int][]int{}
+	var roots []int
+	for i := range input {
+		root := find(i)
+		if _, ok := groups[root]; !ok {
+			roots = append(roots, root)
+		}
+		groups[root] = append(groups[root], i)
+	}
+
+	result := make(members, 0, len(roots))
+	for _, root := range roots {
+		var group members
+		alias := ""
+		for _, i := range groups[root] {
+			group = append(group, input[i])
+			if aliasOfMember[i] != "" {
+				alias = aliasOfMember[i]
+			}
+		}
+		result = append(result, instance.mergeGroup(alias, group))
+	}
+	return result
+}
+
+func (instance identities) mergeGroup(alias string, group members) member {
+	// The primary member is the one whose login matches the alias, otherwise
+	// the first one by account reference to stay deterministic.
+	sort.SliceStable(group, func(i, j int) bool {
+		if alias != "" && (group[i].Name == alias) != (group[j].Name == alias) {
+			return group[i].Name == alias
+		}
+		return group[i].accounts()[0].reference() < group[j].accounts()[0].reference()
+	})
+
+	result := group[0]
+	result.Accounts = nil
+	seen := map[string]bool{}
+	for _, candidate := range group {
+		for _, account := range candidate.accounts() {
+			if !seen[account.reference()] {
+				seen[account.reference()] = true
+				result.Accounts = append(result.Accounts, account)
+			}
+		}
+	}
+	for _, other := range group[1:] {
+		result = result.merge(other)
+		if result.ImageAsset == "" {
+			result.ImageAsset = other.ImageAsset
+		}
+		if result.Fullname == result.Name && other.Fullname != other.Name {
+			result.Fullname = other.Fullname
+		}
+	}
+	if alias != "" {
+		result.Name = alias
+	}
+	return result
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+type identitiesSuite struct{}
+
+var _ = Suite(&identitiesSuite{})
+
+func (s *identitiesSuite) TestResolveByAlias(c *C) {
+	instance := identities{Aliases: map[string][]string{
+		"jdoe": {"github:jdoe", "gitlab:john.doe"},
+	}}
+
+	actual := instance.resolve(members{
+		{Type: "user:gitlab", Name: "john.doe", Fullname: "John Doe", ProfileUrl: "https://gitlab.com/john.doe", Location: pString("Berlin")},
+		{Type: "user:github", Name: "jdoe", Fullname: "jdoe", ProfileUrl: "https://github.com/jdoe", ImageAsset: "a.png"},
+		{Type: "user:gitlab", Name: "jdoe", Fullname: "Jane Doe", ProfileUrl: "https://gitlab.com/jdoe"},
+	})
+
+	c.Assert(actual, HasLen, 2)
+	c.Assert(actual[0].Name, Equals, "jdoe")
+	c.Assert(actual[0].Type, Equals, "user:github")
+	c.Assert(actual[0].Fullname, Equals, "John Doe")
+	c.Assert(actual[0].ImageAsset, Equals, "a.png")
+	c.Assert(*actual[0].Location, Equals, "Berlin")
+	c.Assert(actual[0].Accounts, DeepEquals, []memberAccount{
+		{Type: "user:github", Origin: "github", Login: "jdoe", ProfileUrl: "https://github.com/jdoe", ImageAsset: "a.png"},
+		{Type: "user:gitlab", Origin: "gitlab", Login: "john.doe", ProfileUrl: "https://gitlab.com/john.doe"},
+	})
+
+	// Same login but a different person.
+	c.Assert(actual[1].Fullname, Equals, "Jane Doe")
+	c.Assert(actual[1].Accounts, HasLen, 1)
+}
+
+func (s *identitiesSuite) TestResolveByVerifiedEmail(c *C) {
+	instance := identities{}
+
+	actual := instance.resolve(members{
+		{Type: "user:github", Name: "jdoe", Email: pString("JDoe@example.org"), EmailVerified: true},
+		{Type: "user:gitlab", Name: "john.doe", Email: pString("jdoe@example.org"), EmailVerified: true},
+		{Type: "user:static", Name: "other", Email: pString("jdoe@example.org")},
+	})
+
+	c.Assert(actual, HasLen, 2)
+	c.Assert(actual[0].Name, Equals, "jdoe")
+	c.Assert(actual[0].Accounts, HasLen, 2)
+	c.Assert(actual[1].Name, Equals, "other")
+
+	instance.MatchVerifiedEmails = pBool(false)
+	c.Assert(instance.resolve(members{
+		{Type: "user:github", Name: "jdoe", Email: pString("jdoe@example.org"), EmailVerified: true},
+		{Type: "user:gitlab", Name: "john.doe", Email: pString("jdoe@example.org"), EmailVerified: true},
+	}), HasLen, 2)
+}
+
+func (s *identitiesSuite) TestValidate(c *C) {
+	c.Assert(identities{Aliases: map[string][]string{"a": {"jdoe"}}}.validate(), ErrorMatches, ".*not of format.*")
+	c.Assert(identities{Aliases: map[string][]string{"a": {"github:x"}, "b": {"github:x"}}}.validate(), ErrorMatches, ".*for both.*")
+}
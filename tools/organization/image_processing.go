@@ -0,0 +1,222 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	// GIFs are decoded, too; only their first frame is used.
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	log "github.com/echocat/slf4g"
+	"golang.org/x/image/draw"
+)
+
+var (
+	imageVariantSizes = flag.String("image-variants", "96,192,384", "Comma separated edge lengths of the square thumbnails which are created of every image. Empty to disable.")
+)
+
+// maximumImagePixels is the number of pixels (width * height) up to which
+// images are decoded to create their variants. Decoding needs about 4 bytes
+// per pixel, regardless of how small the file is.
+const maximumImagePixels = 25_000_000
+
+// imageVariant is a square thumbnail of an image asset.
+type imageVariant struct {
+	Asset  string `json:"asset"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+func parseImageVariantSizes(in string) ([]int, error) {
+	var result []int
+	for _, part := range strings.Split(in, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		size, err := strconv.Atoi(part)
+		if err != nil || size <= 0 {
+			return nil, fmt.Errorf("illegal image variant size '%s'", part)
+		}
+		result = append(result, size)
+	}
+	sort.Ints(result)
+	return result, nil
+}
+
+// processImages records the variants of the images of all projects and
+// members and creates them if they do not exist yet. Images which cannot be
+// processed, like SVGs, only keep their original asset.
+func (instance *assetClient) processImages(org organization) (organization, error) {
+	sizes, err := parseImageVariantSizes(*imageVariantSizes)
+	if err != nil {
+		return organization{}, err
+	}
+	if len(sizes) == 0 {
+		return org, nil
+	}
+
+	var assets []string
+	seen := map[string]bool{}
+	add := func(asset string) {
+		if asset != "" && !seen[asset] {
+			seen[asset] = true
+			assets = append(assets, asset)
+		}
+	}
+	for _, project := range org.Projects {
+		add(stringOf(project.ImageAsset))
+	}
+	for _, member := range org.Members {
+		add(member.ImageAsset)
+	}
+
+	variants, err := mapConcurrently(assets, func(asset string) ([]imageVariant, error) {
+		result, err := instance.variantsOf(asset, sizes)
+		if err != nil {
+			log.WithError(err).
+				With("asset", asset).
+				Warn("Cannot process image; only the original will be used.")
+		}
+		return result, nil
+	})
+	if err != nil {
+		return organization{}, err
+	}
+	variantsOfAsset := map[string][]imageVariant{}
+	for i, asset := range assets {
+		variantsOfAsset[asset] = variants[i]
+	}
+
+	result := org
+	result.Projects = append(projects{}, org.Projects...)
+	for i, project := range result.Projects {
+		result.Projects[i].ImageVariants = variantsOfAsset[stringOf(project.ImageAsset)]
+	}
+	result.Members = append(members{}, org.Members...)
+	for i, member := range result.Members {
+		result.Members[i].ImageVariants = variantsOfAsset[member.ImageAsset]
+	}
+	return result, nil
+}
+
+// variantsOf returns a variant for each of the given sizes which is not
+// larger than the image itself. If the image is smaller than all sizes, there
+// is one variant of its own size. Variants which already exist are not
+// created again.
+func (instance *assetClient) variantsOf(asset string, sizes []int) ([]imageVariant, error) {
+	file := filepath.Join(*assetsFolder, asset)
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open '%s': %w", file, err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	config, format, err := image.DecodeConfig(f)
+	if errors.Is(err, image.ErrFormat) {
+		log.With("asset", asset).
+			Debug("Image format is not supported; no variants will be created.")
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode '%s': %w", file, err)
+	}
+	if pixels := int64(config.Width) * int64(config.Height); pixels > maximumImagePixels {
+		return nil, fmt.Errorf("cannot decode '%s': %dx%d pixels exceed the maximum of %d pixels", file, config.Width, config.Height, maximumImagePixels)
+	}
+
+	edge := min(config.Width, config.Height)
+	var targets []int
+	for _, size := range sizes {
+		if size <= edge {
+			targets = append(targets, size)
+		}
+	}
+	if len(targets) == 0 && edge > 0 {
+		targets = []int{edge}
+	}
+
+	// Photos stay photos; everything else might be transparent.
+	ext := ".png"
+	if format == "jpeg" {
+		ext = ".jpg"
+	}
+	base := strings.TrimSuffix(asset, filepath.Ext(asset))
+
+	var src image.Image
+	result := make([]imageVariant, len(targets))
+	for i, size := range targets {
+		result[i] = imageVariant{
+			Asset:  fmt.Sprintf("%s-%d%s", base, size, ext),
+			Width:  size,
+			Height: size,
+		}
+		if instance.exists(result[i].Asset) {
+			continue
+		}
+		if src == nil {
+			if _, err := f.Seek(0, io.SeekStart); err != nil {
+				return nil, fmt.Errorf("cannot read '%s': %w", file, err)
+			}
+			if src, _, err = image.Decode(f); err != nil {
+				return nil, fmt.Errorf("cannot decode '%s': %w", file, err)
+			}
+		}
+		if err := writeImageVariant(src, size, result[i].Asset); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// writeImageVariant scales the center square of the given image to the given
+// size. Metadata of the original is not carried over because only the pixels
+// are encoded again.
+func writeImageVariant(src image.Image, size int, asset string) error {
+	bounds := src.Bounds()
+	edge := min(bounds.Dx(), bounds.Dy())
+	crop := image.Rect(0, 0, edge, edge).Add(image.Pt(
+		bounds.Min.X+(bounds.Dx()-edge)/2,
+		bounds.Min.Y+(bounds.Dy()-edge)/2,
+	))
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, crop, draw.Src, nil)
+
+	w, err := os.CreateTemp(*assetsFolder, "~*"+filepath.Ext(asset))
+	if err != nil {
+		return fmt.Errorf("cannot temporary file: %w", err)
+	}
+	defer func() {
+		_ = w.Close()
+		_ = os.Remove(w.Name())
+	}()
+
+	if filepath.Ext(asset) == ".jpg" {
+		err = jpeg.Encode(w, dst, &jpeg.Options{Quality: 85})
+	} else {
+		err = (&png.Encoder{CompressionLevel: png.BestCompression}).Encode(w, dst)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot encode variant '%s': %w", asset, err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("cannot close '%s': %w", w.Name(), err)
+	}
+
+	target := filepath.Join(*assetsFolder, asset)
+	if err := os.Rename(w.Name(), target); err != nil {
+		return fmt.Errorf("cannot rename '%s' to '%s': %w", w.Name(), target, err)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+)
+
+type imageProcessingSuite struct{}
+
+var _ = Suite(&imageProcessingSuite{})
+
+func (s *imageProcessingSuite) writePng(c *C, name string, width, height int) {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 0xff, A: 0xff})
+		}
+	}
+	f, err := os.Create(filepath.Join(*assetsFolder, name))
+	c.Assert(err, IsNil)
+	defer func() { _ = f.Close() }()
+	c.Assert(png.Encode(f, img), IsNil)
+}
+
+func (s *imageProcessingSuite) TestCreatesSquareVariants(c *C) {
+	*assetsFolder = c.MkDir()
+	s.writePng(c, "large.png", 500, 300)
+	s.writePng(c, "small.png", 50, 60)
+	c.Assert(os.WriteFile(filepath.Join(*assetsFolder, "logo.svg"), []byte("<svg/>"), 0644), IsNil)
+
+	actual, err := newAssetClient(nil).processImages(organization{
+		Projects: projects{{Name: "logo", ImageAsset: pString("logo.svg")}},
+		Members: members{
+			{Name: "large", ImageAsset: "large.png"},
+			{Name: "small", ImageAsset: "small.png"},
+			{Name: "none"},
+		},
+	})
+	c.Assert(err, IsNil)
+
+	c.Assert(actual.Projects[0].ImageVariants, IsNil)
+	c.Assert(actual.Members[0].ImageVariants, DeepEquals, []imageVariant{
+		{Asset: "large-96.png", Width: 96, Height: 96},
+		{Asset: "large-192.png", Width: 192, Height: 192},
+	})
+	c.Assert(actual.Members[1].ImageVariants, DeepEquals, []imageVariant{
+		{Asset: "small-50.png", Width: 50, Height: 50},
+	})
+	c.Assert(actual.Members[2].ImageVariants, IsNil)
+
+	f, err := os.Open(filepath.Join(*assetsFolder, "large-192.png"))
+	c.Assert(err, IsNil)
+	defer func() { _ = f.Close() }()
+	config, format, err := image.DecodeConfig(f)
+	c.Assert(err, IsNil)
+	c.Assert(format, Equals, "png")
+	c.Assert(config.Width, Equals, 192)
+	c.Assert(config.Height, Equals, 192)
+}
+
+func (s *imageProcessingSuite) TestRejectsImagesWithTooManyPixels(c *C) {
+	*assetsFolder = c.MkDir()
+	// Only the header of a PNG with 100000x100000 pixels, which would require
+	// about 40GB if decoded.
+	header := make([]byte, 13)
+	binary.BigEndian.PutUint32(header[0:], 100000)
+	binary.BigEndian.PutUint32(header[4:], 100000)
+	header[8], header[9] = 8, 6 // 8 bit RGBA
+	chunk := append([]byte("IHDR"), header...)
+	b := []byte(pngSignature)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(header)))
+	b = append(b, chunk...)
+	b = binary.BigEndian.AppendUint32(b, crc32.ChecksumIEEE(chunk))
+	c.Assert(os.WriteFile(filepath.Join(*assetsFolder, "huge.png"), b, 0644), IsNil)
+
+	actual, err := newAssetClient(nil).variantsOf("huge.png", []int{96})
+	c.Assert(err, ErrorMatches, ".*100000x100000 pixels exceed the maximum of 25000000 pixels")
+	c.Assert(actual, IsNil)
+}
+
+func (s *imageProcessingSuite) TestParseImageVariantSizes(c *C) {
+	actual, err := parseImageVariantSizes("384, 96,192")
+	c.Assert(err, IsNil)
+	c.Assert(actual, DeepEquals, []int{96, 192, 384})
+
+	actual, err = parseImageVariantSizes("")
+	c.Assert(err, IsNil)
+	c.Assert(actual, HasLen, 0)
+
+	_, err = parseImageVariantSizes("96,big")
+	c.Assert(err, ErrorMatches, "illegal image variant size 'big'")
+}
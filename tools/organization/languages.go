@@ -0,0 +1,134 @@
+package main
+
+import (
+	"math"
+	"strings"
+)
+
+// languageAliases maps the lowercase names under which providers report a
+// language to the name used in the organization.
+var languageAliases = map[string]string{
+	"go":           "Golang",
+	"golang":       "Golang",
+	"c++":          "C++",
+	"cpp":          "C++",
+	"c#":           "C#",
+	"csharp":       "C#",
+	"f#":           "F#",
+	"fsharp":       "F#",
+	"javascript":   "JavaScript",
+	"js":           "JavaScript",
+	"typescript":   "TypeScript",
+	"ts":           "TypeScript",
+	"shell":        "Shell",
+	"bash":         "Shell",
+	"sh":           "Shell",
+	"batchfile":    "Batchfile",
+	"batch":        "Batchfile",
+	"powershell":   "PowerShell",
+	"dockerfile":   "Dockerfile",
+	"docker":       "Dockerfile",
+	"makefile":     "Makefile",
+	"make":         "Makefile",
+	"objective-c":  "Objective-C",
+	"objc":         "Objective-C",
+	"vim script":   "Vim Script",
+	"vim snippet":  "Vim Script",
+	"viml":         "Vim Script",
+	"html":         "HTML",
+	"css":          "CSS",
+	"scss":         "SCSS",
+	"hcl":          "HCL",
+	"java":         "Java",
+	"kotlin":       "Kotlin",
+	"python":       "Python",
+	"ruby":         "Ruby",
+	"rust":         "Rust",
+	"php":          "PHP",
+	"groovy":       "Groovy",
+	"lua":          "Lua",
+	"smarty":       "Smarty",
+	"go template":  "Go Template",
+	"gotemplate":   "Go Template",
+	"jupyter":      "Jupyter Notebook",
+	"plpgsql":      "PLpgSQL",
+	"pl/pgsql":     "PLpgSQL",
+	"tsql":         "TSQL",
+	"emacs lisp":   "Emacs Lisp",
+	"elisp":        "Emacs Lisp",
+	"common lisp":  "Common Lisp",
+	"visual basic": "Visual Basic .NET",
+	"vb.net":       "Visual Basic .NET",
+}
+
+// canonicalLanguage returns the name of the given language as used in the
+// organization. Languages without alias are taken as they are.
+func canonicalLanguage(name string) string {
+	name = strings.TrimSpace(name)
+	if canonical, ok := languageAliases[strings.ToLower(name)]; ok {
+		return canonical
+	}
+	return name
+}
+
+// languagesOfBytes returns the percentage of every language of the given
+// number of bytes per language.
+func languagesOfBytes(bytes map[string]int) map[string]float64 {
+	var total int
+	for _, size := range bytes {
+		total += size
+	}
+	if total <= 0 {
+		return nil
+	}
+	percentages := make(map[string]float64, len(bytes))
+	for language, size := range bytes {
+		percentages[language] = float64(size) * 100 / float64(total)
+	}
+	return languagesOfPercentages(percentages)
+}
+
+// languagesOfPercentages canonicalizes the names of the given languages.
+// Languages which end up with the same name are summed up.
+func languagesOfPercentages(percentages map[string]float64) map[string]float64 {
+	if len(percentages) == 0 {
+		return nil
+	}
+	result := make(map[string]float64, len(percentages))
+	for language, percentage := range percentages {
+		result[canonicalLanguage(language)] += percentage
+	}
+	for language, percentage := range result {
+		result[language] = roundPercentage(percentage)
+	}
+	return result
+}
+
+// dominantLanguageOf returns the language with the highest percentage.
+func dominantLanguageOf(languages map[string]float64) (result string) {
+	maximum := -1.0
+	for language, percentage := range languages {
+		// The name decides on a tie to be independent of the map order.
+		if percentage > maximum || (percentage == maximum && language < result) {
+			maximum = percentage
+			result = language
+		}
+	}
+	return
+}
+
+func roundPercentage(in float64) float64 {
+	return math.Round(in*100) / 100
+}
+
+// languages returns Languages or - if the provider only reports the dominant
+// language - the Language with 100%.
+func (instance project) languages() map[string]float64 {
+	if len(instance.Languages) > 0 {
+		return instance.Languages
+	}
+	if instance.Language != nil && *instance.Language != "" {
+		return map[string]float64{*instance.Language: 100}
+	}
+	return nil
+}
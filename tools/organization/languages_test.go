@@ -0,0 +1,49 @@
+package main
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+type languagesSuite struct{}
+
+var _ = Suite(&languagesSuite{})
+
+func (s *languagesSuite) TestCanonicalLanguage(c *C) {
+	c.Assert(canonicalLanguage("Go"), Equals, "Golang")
+	c.Assert(canonicalLanguage("golang"), Equals, "Golang")
+	c.Assert(canonicalLanguage(" C++ "), Equals, "C++")
+	c.Assert(canonicalLanguage("Zig"), Equals, "Zig")
+	c.Assert(canonicalLanguage(""), Equals, "")
+}
+
+func (s *languagesSuite) TestLanguagesOfBytesMergesAliases(c *C) {
+	actual := languagesOfBytes(map[string]int{
+		"Go":     1000,
+		"golang": 1000,
+		"Shell":  500,
+		"Bash":   500,
+		"HCL":    1,
+	})
+
+	c.Assert(actual, DeepEquals, map[string]float64{"Golang": 66.64, "Shell": 33.32, "HCL": 0.03})
+	c.Assert(dominantLanguageOf(actual), Equals, "Golang")
+	c.Assert(languagesOfBytes(map[string]int{}), IsNil)
+}
+
+func (s *languagesSuite) TestDominantLanguageOfIsStableOnTies(c *C) {
+	c.Assert(dominantLanguageOf(map[string]float64{"Shell": 50, "Java": 50}), Equals, "Java")
+	c.Assert(dominantLanguageOf(nil), Equals, "")
+}
+
+func (s *languagesSuite) TestStatisticsAverageLanguages(c *C) {
+	org := organization{Projects: projects{
+		{Name: "a", Languages: map[string]float64{"Golang": 80, "Shell": 20}},
+		// Providers which only report the dominant language.
+		{Name: "b", Language: pString("Java")},
+		{Name: "c"},
+		{Name: "d", Language: pString("Rust"), Hidden: true},
+	}}
+	org.align()
+
+	c.Assert(org.Statistics.Languages, DeepEquals, map[string]float64{"Golang": 40, "Shell": 10, "Java": 50})
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	log "github.com/echocat/slf4g"
+)
+
+type license struct {
+	SpdxId string `json:"spdxId"`
+	Name   string `json:"name"`
+	Url    string `json:"url"`
+}
+
+// licenseFileNames are tried in this order to find the license of a project.
+var licenseFileNames = []string{"LICENSE", "LICENSE.md", "LICENSE.txt", "LICENCE", "COPYING"}
+
+type knownLicense struct {
+	spdxId string
+	name   string
+	// title is the headline of the license text in normalized form. It is
+	// only searched for at the beginning of the text, as other licenses cite
+	// it in their bodies; for example the GPL-3.0 refers to the AGPL-3.0 and
+	// the MPL-2.0 lists both as secondary licenses.
+	title string
+	// phrases which all have to be contained in the normalized text of
+	// licenses without a title.
+	phrases []string
+	// excludes must not be contained in the normalized text of the license.
+	excludes []string
+}
+
+// knownLicenses are used both to complete licenses reported by the providers
+// and to classify license files. The order of the licenses without title
+// matters for the classification: more specific licenses come first.
+var knownLicenses = []knownLicense{
+	{spdxId: "AGPL-3.0", name: "GNU Affero General Public License v3.0", title: "gnu affero general public license version 3"},
+	{spdxId: "LGPL-3.0", name: "GNU Lesser General Public License v3.0", title: "gnu lesser general public license version 3"},
+	{spdxId: "LGPL-2.1", name: "GNU Lesser General Public License v2.1", title: "gnu lesser general public license version 2.1"},
+	{spdxId: "GPL-3.0", name: "GNU General Public License v3.0", title: "gnu general public license version 3"},
+	{spdxId: "GPL-2.0", name: "GNU General Public License v2.0", title: "gnu general public license version 2"},
+	{spdxId: "Apache-2.0", name: "Apache License 2.0", title: "apache license version 2.0"},
+	{spdxId: "MPL-2.0", name: "Mozilla Public License 2.0", title: "mozilla public license version 2.0"},
+	{spdxId: "EPL-2.0", name: "Eclipse Public License 2.0", title: "eclipse public license v 2.0"},
+	{spdxId: "BSL-1.0", name: "Boost Software License 1.0", title: "boost software license version 1.0"},
+	{spdxId: "CC0-1.0", name: "Creative Commons Zero v1.0 Universal", title: "cc0 1.0 universal"},
+	{spdxId: "Unlicense", name: "The Unlicense", phrases: []string{"this is free and unencumbered software released into the public domain"}},
+	{spdxId: "BSD-3-Clause", name: "BSD 3-Clause \"New\" or \"Revised\" License", phrases: []string{"redistribution and use in source and binary forms", "neither the name of"}},
+	{spdxId: "BSD-2-Clause", name: "BSD 2-Clause \"Simplified\" License", phrases: []string{"redistribution and use in source and binary forms"}, excludes: []string{"neither the name of"}},
+	{spdxId: "ISC", name: "ISC License", phrases: []string{"permission to use copy modify and or distribute this software for any purpose with or without fee is hereby granted"}},
+	{spdxId: "MIT", name: "MIT License", phrases: []string{"permission is hereby granted free of charge to any person obtaining a copy"}},
+	{spdxId: "Zlib", name: "zlib License", phrases: []string{"this software is provided as is without any express or implied warranty", "altered source versions must be plainly marked as such"}},
+}
+
+// licenseTitleRange is the number of characters at the beginning of the
+// normalized license text in which titles are searched for. It leaves room
+// for a copyright line in front of the title.
+const licenseTitleRange = 500
+
+// licenseOf returns the license of the given SPDX identifier (or GitLab
+// license key, which is the lowercase SPDX identifier). Identifiers which
+// are not known are taken as they are. It returns nil for empty identifiers
+// and the placeholders of providers for unknown licenses.
+func licenseOf(spdxId, name string) *license {
+	switch strings.ToLower(spdxId) {
+	case "", "noassertion", "other":
+		return nil
+	}
+	for _, candidate := range knownLicenses {
+		if strings.EqualFold(candidate.spdxId, spdxId) {
+			return candidate.toLicense()
+		}
+	}
+	if name == "" {
+		name = spdxId
+	}
+	return &license{
+		SpdxId: spdxId,
+		Name:   name,
+		Url:    "https://spdx.org/licenses/" + spdxId + ".html",
+	}
+}
+
+func (instance knownLicense) toLicense() *license {
+	return &license{
+		SpdxId: instance.spdxId,
+		Name:   instance.name,
+		Url:    "https://spdx.org/licenses/" + instance.spdxId + ".html",
+	}
+}
+
+var licenseTextNoise = regexp.MustCompile(`[^a-z0-9.]+`)
+
+// classifyLicense returns the license of the given license text or nil if
+// it is not one of knownLicenses. The license whose title comes first wins;
+// texts without any title are classified by their phrases.
+func classifyLicense(text string) *license {
+	normalized := " " + licenseTextNoise.ReplaceAllString(strings.ToLower(text), " ") + " "
+	// Dots are only relevant inside version numbers.
+	normalized = strings.NewReplacer(". ", " ", " .", " ").Replace(normalized)
+
+	head := normalized
+	if len(head) > licenseTitleRange {
+		head = head[:licenseTitleRange]
+	}
+	var result *knownLicense
+	position := len(head)
+	for i, candidate := range knownLicenses {
+		if candidate.title == "" {
+			continue
+		}
+		// The surrounding spaces prevent "version 2" from matching "version 2.1".
+		if at := strings.Index(head, " "+candidate.title+" "); at >= 0 && at < position {
+			result, position = &knownLicenses[i], at
+		}
+	}
+	if result != nil {
+		return result.toLicense()
+	}
+
+	for _, candidate := range knownLicenses {
+		if candidate.title == "" && candidate.matches(normalized) {
+			return candidate.toLicense()
+		}
+	}
+	return nil
+}
+
+func (instance knownLicense) matches(normalized string) bool {
+	for _, phrase := range instance.phrases {
+		if !strings.Contains(normalized, phrase) {
+			return false
+		}
+	}
+	for _, exclude := range instance.excludes {
+		if strings.Contains(normalized, exclude) {
+			return false
+		}
+	}
+	return true
+}
+
+// resolveLicenses classifies the license file of every project whose
+// provider did not report a license.
+func resolveLicenses(input projects) projects {
+	result, _ := mapConcurrently(input, func(candidate project) (project, error) {
+		if candidate.License == nil && candidate.fileFetcher != nil {
+			candidate.License = resolveLicenseOf(candidate)
+		}
+		return candidate, nil
+	})
+	return result
+}
+
+func resolveLicenseOf(candidate project) *license {
+	l := log.With("project", candidate.Origin+":"+candidate.Fullname)
+	for _, name := range licenseFileNames {
+		content, found, err := candidate.fileFetcher(name)
+		if err != nil {
+			l.WithError(err).
+				With("file", name).
+				Warn("Cannot retrieve license file; the license stays unknown.")
+			return nil
+		}
+		if !found {
+			continue
+		}
+		result := classifyLicense(content)
+		if result == nil {
+			l.With("file", name).
+				Info("License file does not contain a known license.")
+		}
+		return result
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+)
+
+type licensesSuite struct{}
+
+var _ = Suite(&licensesSuite{})
+
+func (s *licensesSuite) TestClassifyLicense(c *C) {
+	cases := map[string]string{
+		"MIT License\n\nCopyright (c) 2020 echocat\n\nPermission is hereby granted, free of charge, to any person obtaining a copy\nof this software":                          "MIT",
+		"Copyright (c) 2020, echocat\n\nRedistribution and use in source and binary forms, with or without\nmodification, are permitted provided that ... Neither the name of": "BSD-3-Clause",
+		"Copyright (c) 2020, echocat\n\nRedistribution and use in source and binary forms, with or without\nmodification, are permitted":                                       "BSD-2-Clause",
+		"GNU LESSER GENERAL PUBLIC LICENSE\nVersion 2.1, February 1999\n\n... GNU General Public License ...":                                                                  "LGPL-2.1",
+		"GNU GENERAL PUBLIC LICENSE\nVersion 2, June 1991":                                                                                       "GPL-2.0",
+		"Mozilla Public License Version 2.0\n==================================":                                                                 "MPL-2.0",
+		"ISC License\n\nPermission to use, copy, modify, and/or distribute this software for any\npurpose with or without fee is hereby granted": "ISC",
+		"This is free and unencumbered software released into the public domain.":                                                                "Unlicense",
+	}
+	for text, expected := range cases {
+		actual := classifyLicense(text)
+		c.Assert(actual, NotNil, Commentf("%s", text))
+		c.Assert(actual.SpdxId, Equals, expected, Commentf("%s", text))
+	}
+
+	c.Assert(classifyLicense("All rights reserved."), IsNil)
+}
+
+func (s *licensesSuite) TestClassifyLicenseOfFullTexts(c *C) {
+	// The texts cite each other: the GPL-3.0 refers to the AGPL-3.0 and the
+	// MPL-2.0 lists the GPL, LGPL and AGPL as secondary licenses. The
+	// AGPL-3.0 is derived from the GPL-3.0 by its title, preamble and
+	// section 13.
+	for _, expected := range []string{"AGPL-3.0", "GPL-3.0", "LGPL-3.0", "MPL-2.0"} {
+		b, err := os.ReadFile(filepath.Join("testdata", "licenses", expected))
+		c.Assert(err, IsNil)
+
+		actual := classifyLicense(string(b))
+		c.Assert(actual, NotNil, Commentf("%s", expected))
+		c.Assert(actual.SpdxId, Equals, expected)
+
+		// A copyright line in front of the title does not matter.
+		actual = classifyLicense("Copyright (c) 2020 echocat\n\n" + string(b))
+		c.Assert(actual, NotNil, Commentf("%s", expected))
+		c.Assert(actual.SpdxId, Equals, expected)
+	}
+}
+
+func (s *licensesSuite) TestLicenseOf(c *C) {
+	c.Assert(*licenseOf("apache-2.0", "Apache License 2.0"), DeepEquals, license{
+		SpdxId: "Apache-2.0",
+		Name:   "Apache License 2.0",
+		Url:    "https://spdx.org/licenses/Apache-2.0.html",
+	})
+	c.Assert(*licenseOf("WTFPL", "Do What The F*ck You Want To Public License"), DeepEquals, license{
+		SpdxId: "WTFPL",
+		Name:   "Do What The F*ck You Want To Public License",
+		Url:    "https://spdx.org/licenses/WTFPL.html",
+	})
+	c.Assert(licenseOf("NOASSERTION", "Other"), IsNil)
+	c.Assert(licenseOf("other", "Other"), IsNil)
+	c.Assert(licenseOf("", ""), IsNil)
+}
+
+func (s *licensesSuite) TestStatisticsCountLicenses(c *C) {
+	org := organization{Projects: projects{
+		{Name: "a", License: licenseOf("MIT", "")},
+		{Name: "b", License: licenseOf("MIT", "")},
+		{Name: "c", License: licenseOf("Apache-2.0", "")},
+		{Name: "d"},
+		{Name: "e", License: licenseOf("MIT", ""), Hidden: true},
+	}}
+	org.align()
+	c.Assert(org.Statistics.Licenses, DeepEquals, map[string]uint32{"MIT": 2, "Apache-2.0": 1})
+}
@@ -16,17 +16,66 @@ func main() {
 	flag.Parse()
 	var err error
 
-	assetClient := newAssetClient()
-	if err := assetClient.cleanTarget(); err != nil {
+	if *offline && *cacheDirectory == "" {
+		log.Fatal("--offline requires --cache.")
+		os.Exit(1)
+	}
+
+	// Not offline, where all entries might still be needed.
+	if *cacheDirectory != "" && !*offline {
+		if err := evictCache(*cacheDirectory, *cacheMaximumAge); err != nil {
+			log.WithError(err).
+				Fatal("Cannot evict outdated cache entries.")
+			os.Exit(1)
+		}
+	}
+
+	if err := assetPolicy(*assetsPolicy).validate(); err != nil {
+		log.WithError(err).
+			Fatal("Illegal value of --assets-policy.")
+		os.Exit(1)
+	}
+
+	config, err := loadConfiguration()
+	if err != nil {
+		log.WithError(err).
+			Fatal("Cannot load configuration.")
+		os.Exit(1)
+	}
+
+	overrides, err := loadOverrides()
+	if err != nil {
+		log.WithError(err).
+			Fatal("Cannot load overrides.")
+		os.Exit(1)
+	}
+
+	categories, err := loadCategories()
+	if err != nil {
+		log.WithError(err).
+			Fatal("Cannot load categories.")
+		os.Exit(1)
+	}
+
+	transport, err := defaultTransportConfiguration().newTransport()
+	if err != nil {
 		log.WithError(err).
-			Fatal("Cannot clean target.")
+			Fatal("Cannot create transport.")
 		os.Exit(1)
 	}
 
-	client := &compoundClient{
-		newGithubClient(assetClient),
-		newGitlabClient(assetClient),
+	assetClient := newAssetClient(transport)
+
+	client, err := config.newClients(assetClient)
+	if err != nil {
+		log.WithError(err).
+			Fatal("Cannot create clients.")
+		os.Exit(1)
+	}
+	if *resilient {
+		client.previousFile = *output
 	}
+	client.goModuleProxy = newGoModuleProxy(transport)
 	org, err := client.retrieveOrganization()
 	if err != nil {
 		log.WithError(err).
@@ -34,10 +83,47 @@ func main() {
 		os.Exit(1)
 	}
 
+	if overrides != nil {
+		if org, err = overrides.apply(org, assetClient); err != nil {
+			log.WithError(err).
+				Fatal("Cannot apply overrides.")
+			os.Exit(1)
+		}
+	}
+
+	// After the overrides, because an overridden category wins.
+	if categories != nil {
+		org = categories.apply(org)
+	}
+
+	if org, err = assetClient.generateFallbackImages(org); err != nil {
+		log.WithError(err).
+			Fatal("Cannot generate fallback images.")
+		os.Exit(1)
+	}
+
+	if org, err = assetClient.processImages(org); err != nil {
+		log.WithError(err).
+			Fatal("Cannot process images.")
+		os.Exit(1)
+	}
+
 	if err := org.save(*output); err != nil {
 		log.WithError(err).
 			Fatal("Cannot start database.")
 		os.Exit(1)
 	}
 
+	// Only now the assets of the previous run are no longer needed.
+	if err := assetClient.collectGarbage(org, *assetsGcGrace); err != nil {
+		log.WithError(err).
+			Fatal("Cannot remove unreferenced assets.")
+		os.Exit(1)
+	}
+
+	if org.degraded() {
+		log.Warn("At least one source could not be retrieved; the organization is degraded.")
+		os.Exit(exitCodeDegraded)
+	}
+
 }
@@ -0,0 +1,358 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	log "github.com/echocat/slf4g"
+)
+
+// mirrors detect projects which are mirrored between providers and collapse
+// each set into one project with a primary origin.
+type mirrors struct {
+	// Counters decides how stars, forks and watchers of the mirrors are
+	// handled: "sum" (default) adds them to the primary project, "primary"
+	// only uses the ones of the primary project.
+	Counters mirrorCountersPolicy `yaml:"counters"`
+	// PrimaryOrigins are the preferred origins of the primary project if
+	// this cannot be determined from the mirror relation itself.
+	PrimaryOrigins []string `yaml:"primaryOrigins"`
+	// DetectByRootCommit links projects with identical names if they share
+	// the same root commit. This requires additional API calls.
+	DetectByRootCommit bool `yaml:"detectByRootCommit"`
+	// Explicit mirror relations which are not detectable.
+	Explicit []explicitMirror `yaml:"explicit"`
+}
+
+type mirrorCountersPolicy string
+
+const (
+	mirrorCountersSum     = mirrorCountersPolicy("sum")
+	mirrorCountersPrimary = mirrorCountersPolicy("primary")
+)
+
+// explicitMirror references projects by either their fullname or
+// "<origin>:<fullname>".
+type explicitMirror struct {
+	Primary string   `yaml:"primary"`
+	Mirrors []string `yaml:"mirrors"`
+}
+
+type projectMirror struct {
+	Type               string  `json:"type"`
+	Origin             string  `json:"origin"`
+	Source             string  `json:"source"`
+	Fullname           string  `json:"fullname"`
+	ProfileUrl         string  `json:"profileUrl"`
+	HttpCloneUrl       *string `json:"httpCloneUrl"`
+	SshCloneUrl        *string `json:"sshCloneUrl"`
+	NumberOfForks      *uint32 `json:"numberOfForks"`
+	NumberOfOpenIssues *uint32 `json:"numberOfOpenIssues"`
+	NumberOfStars      *uint32 `json:"numberOfStars"`
+	NumberOfWatchers   *uint32 `json:"numberOfWatchers"`
+}
+
+// projectCounters are the counters of a project which are summed up over its
+// mirrors.
+type projectCounters struct {
+	NumberOfForks    *uint32 `json:"numberOfForks"`
+	NumberOfStars    *uint32 `json:"numberOfStars"`
+	NumberOfWatchers *uint32 `json:"numberOfWatchers"`
+}
+
+func (instance mirrors) validate() error {
+	switch instance.Counters {
+	case "", mirrorCountersSum, mirrorCountersPrimary:
+	default:
+		return fmt.Errorf("mirrors.counters has the illegal value '%s'", instance.Counters)
+	}
+	for i, explicit := range instance.Explicit {
+		if explicit.Primary == "" || len(explicit.Mirrors) == 0 {
+			return fmt.Errorf("mirrors.explicit[%d] requires a primary and at least one mirror", i)
+		}
+	}
+	return nil
+}
+
+func (instance mirrors) collapse(input projects) projects {
+	// Union-find over the indexes of input; primaries contains all projects
+	// which are known to be the primary of another project.
+	parents := make([]int, len(input))
+	for i := range parents {
+		parents[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parents[i] != i {
+			parents[i] = find(parents[i])
+		}
+		return parents[i]
+	}
+	primaries := map[int]bool{}
+	link := func(primary, mirror int) {
+		if rp, rm := find(primary), find(mirror); rp != rm {
+			parents[rm] = rp
+		}
+		primaries[primary] = true
+	}
+
+	for _, explicit := range instance.Explicit {
+		primary := input.indexOf(explicit.Primary)
+		if primary < 0 {
+			log.With("project", explicit.Primary).
+				Warn("Explicit mirror relation references an unknown primary project; it will be ignored.")
+			continue
+		}
+		for _, reference := range explicit.Mirrors {
+			if mirror := input.indexOf(reference); mirror < 0 {
+				log.With("project", reference).
+					Warn("Explicit mirror relation references an unknown mirror project; it will be ignored.")
+			} else {
+				link(primary, mirror)
+			}
+		}
+	}
+
+	byUrl := map[string]int{}
+	for i, candidate := range input {
+		for _, u := range candidate.urls() {
+			byUrl[u] = i
+		}
+	}
+	for i, candidate := range input {
+		if candidate.mirrorOf == "" {
+			continue
+		}
+		if primary, ok := byUrl[normalizeRepositoryUrl(candidate.mirrorOf)]; ok && primary != i {
+			link(primary, i)
+		}
+	}
+
+	if instance.DetectByRootCommit {
+		instance.linkByRootCommit(input, func(a, b int) {
+			if find(a) != find(b) {
+				parents[find(b)] = find(a)
+			}
+		})
+	}
+
+	groups := map[int][]int{}
+	var roots []int
+	for i := range input {
+		root := find(i)
+		if _, ok := groups[root]; !ok {
+			roots = append(roots, root)
+		}
+		groups[root] = append(groups[root], i)
+	}
+
+	result := make(projects, 0, len(roots))
+	for _, root := range roots {
+		group := groups[root]
+		if len(group) == 1 {
+			result = append(result, input[group[0]])
+			continue
+		}
+		result = append(result, instance.mergeGroup(input, group, primaries))
+	}
+	return result
+}
+
+func (instance mirrors) linkByRootCommit(input projects, link func(a, b int)) {
+	byName := map[string][]int{}
+	for i, candidate := range input {
+		byName[strings.ToLower(candidate.Name)] = append(byName[strings.ToLower(candidate.Name)], i)
+	}
+	for _, name := range sortedKeys(byName) {
+		candidates := byName[name]
+		if len(candidates) < 2 {
+			continue
+		}
+		byRootCommit := map[string]int{}
+		for _, i := range candidates {
+			if input[i].rootCommitResolver == nil {
+				continue
+			}
+			rootCommit, err := input[i].rootCommitResolver()
+			if err != nil {
+				log.WithError(err).
+					With("project", input[i].Origin+":"+input[i].Fullname).
+					Warn("Cannot resolve root commit; it will not be considered as mirror.")
+				continue
+			}
+			if rootCommit == "" {
+				continue
+			}
+			if existing, ok := byRootCommit[rootCommit]; ok {
+				link(existing, i)
+			} else {
+				byRootCommit[rootCommit] = i
+			}
+		}
+	}
+}
+
+func (instance mirrors) mergeGroup(input projects, group []int, primaries map[int]bool) project {
+	originRank := func(origin string) int {
+		for i, candidate := range instance.PrimaryOrigins {
+			if candidate == origin {
+				return i
+			}
+		}
+		return len(instance.PrimaryOrigins)
+	}
+	sort.SliceStable(group, func(i, j int) bool {
+		a, b := input[group[i]], input[group[j]]
+		if primaries[group[i]] != primaries[group[j]] {
+			return primaries[group[i]]
+		}
+		if (a.mirrorOf == "") != (b.mirrorOf == "") {
+			return a.mirrorOf == ""
+		}
+		if ra, rb := originRank(a.Origin), originRank(b.Origin); ra != rb {
+			return ra < rb
+		}
+		return a.Origin+":"+a.Fullname < b.Origin+":"+b.Fullname
+	})
+
+	result := input[group[0]]
+	result.Mirrors = append([]projectMirror{}, result.Mirrors...)
+	result.OwnCounters = &projectCounters{
+		NumberOfForks:    result.NumberOfForks,
+		NumberOfStars:    result.NumberOfStars,
+		NumberOfWatchers: result.NumberOfWatchers,
+	}
+	for _, i := range group[1:] {
+		mirror := input[i]
+		result.Mirrors = append(result.Mirrors, mirror.toMirror())
+		if instance.Counters != mirrorCountersPrimary {
+			result.NumberOfStars = addUint32(result.NumberOfStars, mirror.NumberOfStars)
+			result.NumberOfForks = addUint32(result.NumberOfForks, mirror.NumberOfForks)
+			result.NumberOfWatchers = addUint32(result.NumberOfWatchers, mirror.NumberOfWatchers)
+		}
+		if mirror.UpdatedAt != nil && (result.UpdatedAt == nil || mirror.UpdatedAt.After(*result.UpdatedAt)) {
+			result.UpdatedAt = pTime(*mirror.UpdatedAt)
+		}
+		if result.Description == nil && mirror.Description != nil {
+			result.Description = pString(*mirror.Description)
+		}
+		if result.ImageAsset == nil && mirror.ImageAsset != nil {
+			result.ImageAsset = pString(*mirror.ImageAsset)
+		}
+	}
+	return result
+}
+
+func (instance project) toMirror() projectMirror {
+	return projectMirror{
+		Type:               instance.Type,
+		Origin:             instance.Origin,
+		Source:             instance.Source,
+		Fullname:           instance.Fullname,
+		ProfileUrl:         instance.ProfileUrl,
+		HttpCloneUrl:       instance.HttpCloneUrl,
+		SshCloneUrl:        instance.SshCloneUrl,
+		NumberOfForks:      instance.NumberOfForks,
+		NumberOfOpenIssues: instance.NumberOfOpenIssues,
+		NumberOfStars:      instance.NumberOfStars,
+		NumberOfWatchers:   instance.NumberOfWatchers,
+	}
+}
+
+// unmerged returns this project as it was retrieved from its source: without
+// its Mirrors and with its OwnCounters. It is used for the last known data of
+// a source which is collapsed again with the data of the other sources.
+func (instance project) unmerged() project {
+	result := instance
+	result.Mirrors = nil
+	if own := result.OwnCounters; own != nil {
+		result.NumberOfForks = own.NumberOfForks
+		result.NumberOfStars = own.NumberOfStars
+		result.NumberOfWatchers = own.NumberOfWatchers
+		result.OwnCounters = nil
+	}
+	return result
+}
+
+// toProject re-expands this mirror of the given primary into the project it
+// was before it was collapsed. It mirrors the primary, so it is collapsed
+// with it again.
+func (instance projectMirror) toProject(primary project) project {
+	return project{
+		Type:               instance.Type,
+		Origin:             instance.Origin,
+		Source:             instance.Source,
+		Fullname:           instance.Fullname,
+		Name:               path.Base(instance.Fullname),
+		ProfileUrl:         instance.ProfileUrl,
+		HttpCloneUrl:       instance.HttpCloneUrl,
+		SshCloneUrl:        instance.SshCloneUrl,
+		NumberOfForks:      instance.NumberOfForks,
+		NumberOfOpenIssues: instance.NumberOfOpenIssues,
+		NumberOfStars:      instance.NumberOfStars,
+		NumberOfWatchers:   instance.NumberOfWatchers,
+		mirrorOf:           primary.ProfileUrl,
+	}
+}
+
+// urls returns all normalized URLs which identify this project.
+func (instance project) urls() []string {
+	var result []string
+	for _, candidate := range []*string{instance.HttpCloneUrl, instance.SshCloneUrl, &instance.ProfileUrl} {
+		if candidate != nil && *candidate != "" {
+			result = append(result, normalizeRepositoryUrl(*candidate))
+		}
+	}
+	return result
+}
+
+func (instance projects) indexOf(reference string) int {
+	for i, candidate := range instance {
+		if candidate.matches(reference) {
+			return i
+		}
+	}
+	return -1
+}
+
+// normalizeRepositoryUrl converts HTTP(S), SSH and scp-like git URLs of the
+// same repository into the same "<host>/<path>" form.
+func normalizeRepositoryUrl(in string) string {
+	result := strings.ToLower(strings.TrimSpace(in))
+	if i := strings.Index(result, "://"); i >= 0 {
+		result = result[i+3:]
+	} else if colon := strings.Index(result, ":"); colon >= 0 && !strings.Contains(result[:colon], "/") {
+		// scp-like syntax: git@host:path
+		result = result[:colon] + "/" + result[colon+1:]
+	}
+
+	host, path := result, ""
+	if slash := strings.Index(result, "/"); slash >= 0 {
+		host, path = result[:slash], result[slash:]
+	}
+	if at := strings.LastIndex(host, "@"); at >= 0 {
+		host = host[at+1:]
+	}
+	if colon := strings.Index(host, ":"); colon >= 0 {
+		host = host[:colon]
+	}
+
+	path = strings.TrimSuffix(strings.TrimSuffix(path, "/"), ".git")
+	return host + path
+}
+
+func addUint32(a, b *uint32) *uint32 {
+	if a == nil && b == nil {
+		return nil
+	}
+	var result uint32
+	if a != nil {
+		result += *a
+	}
+	if b != nil {
+		result += *b
+	}
+	return &result
+}
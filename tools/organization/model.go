@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 	"time"
 )
 
@@ -13,58 +14,81 @@ type organization struct {
 	Members    members    `json:"members"`
 	Projects   projects   `json:"projects"`
 	Statistics statistics `json:"statistics"`
+	// Sources contains the status of every configured source.
+	Sources []sourceStatus `json:"sources"`
 }
 
 func (instance organization) merge(with ...organization) (result organization) {
 	result = instance
 
+	// Members are only merged if they are the same account of the same
+	// provider; linking the accounts of one person is done by identities.
+	var keys []string
 	membersAsMap := map[string]member{}
+	add := func(member member) {
+		key := member.Type + ":" + member.Name
+		if existing, ok := membersAsMap[key]; ok {
+			membersAsMap[key] = existing.merge(member)
+		} else {
+			keys = append(keys, key)
+			membersAsMap[key] = member
+		}
+	}
 	for _, member := range instance.Members {
-		membersAsMap[member.Name] = member
+		add(member)
 	}
 
 	for _, in := range with {
 		result.Projects = append(result.Projects, in.Projects...)
 		for _, member := range in.Members {
-			if existing, ok := membersAsMap[member.Name]; ok {
-				membersAsMap[member.Name] = existing.merge(member)
-			} else {
-				membersAsMap[member.Name] = member
-			}
+			add(member)
 		}
 	}
 
 	result.Members = []member{}
-	for _, member := range membersAsMap {
-		result.Members = append(result.Members, member)
+	for _, key := range keys {
+		result.Members = append(result.Members, membersAsMap[key])
 	}
 
 	result.align()
 	return
 }
 
-func (instance organization) clean() (result organization) {
+func (instance organization) clean(rules rules) (result organization) {
 	result = organization{
-		Members:    instance.Members.clean(),
-		Projects:   instance.Projects.clean(),
+		Members:    instance.Members.clean(rules),
+		Projects:   instance.Projects.clean(rules),
 		Statistics: instance.Statistics,
+		Sources:    instance.Sources,
 	}
 
 	result.align()
 	return
 }
 
-func (instance organization) membersAsMap() map[string]member {
-	result := map[string]member{}
-	for _, member := range instance.Members {
-		result[member.Name] = member
-	}
-	return result
-}
-
 func (instance *organization) align() {
+	instance.Statistics = statistics{
+		Licenses:  map[string]uint32{},
+		Languages: map[string]float64{},
+	}
+	var projectsWithLanguages int
 	for _, project := range instance.Projects {
+		if project.Hidden {
+			continue
+		}
 		instance.Statistics.NumberOfProjects++
+		if languages := project.languages(); len(languages) > 0 {
+			projectsWithLanguages++
+			for language, percentage := range languages {
+				instance.Statistics.Languages[language] += percentage
+			}
+		}
+		if project.License != nil {
+			instance.Statistics.Licenses[project.License.SpdxId]++
+		}
+		if project.NumberOfRecentReleases != nil {
+			instance.Statistics.NumberOfRecentReleases += *project.NumberOfRecentReleases
+		}
 		if project.NumberOfOpenIssues != nil {
 			instance.Statistics.NumberOfOpenIssues += *project.NumberOfOpenIssues
 		}
@@ -78,9 +102,19 @@ func (instance *organization) align() {
 			instance.Statistics.NumberOfForks += *project.NumberOfForks
 		}
 	}
-	instance.Statistics.NumberOfMembers = uint32(len(instance.Members))
+	for language, percentage := range instance.Statistics.Languages {
+		instance.Statistics.Languages[language] = roundPercentage(percentage / float64(projectsWithLanguages))
+	}
+	for _, member := range instance.Members {
+		if !member.Hidden {
+			instance.Statistics.NumberOfMembers++
+		}
+	}
 	sort.Sort(instance.Members)
 	sort.Sort(instance.Projects)
+	// After sorting, because the first account of a person represents it.
+	instance.Statistics.Contributors = instance.Projects.rankContributors()
+	instance.Statistics.NumberOfContributors = uint32(len(instance.Statistics.Contributors))
 }
 
 func (instance organization) save(to string) (err error) {
@@ -108,31 +142,97 @@ func (instance organization) save(to string) (err error) {
 }
 
 type project struct {
-	Type               string     `json:"type"`
-	Origin             string     `json:"origin"`
-	Fullname           string     `json:"fullname"`
-	Name               string     `json:"name"`
-	Description        *string    `json:"description"`
-	DefaultBranch      *string    `json:"defaultBranch"`
-	Language           *string    `json:"language"`
-	HomepageUrl        *string    `json:"homepageUrl"`
-	ImageAsset         *string    `json:"imageAsset"`
-	ProfileUrl         string     `json:"profileUrl"`
-	HttpCloneUrl       *string    `json:"httpCloneUrl"`
-	SshCloneUrl        *string    `json:"sshCloneUrl"`
-	IssuesUrl          *string    `json:"issuesUrl"`
-	WikiUrl            *string    `json:"wikiUrl"`
-	ForksUrl           *string    `json:"forksUrl"`
-	PullRequestsUrl    *string    `json:"pullRequestsUrl"`
-	CreateForkUrl      *string    `json:"createForkUrl"`
-	StarsUrl           *string    `json:"starsUrl"`
-	WatchersUrl        *string    `json:"watchersUrl"`
-	NumberOfForks      *uint32    `json:"numberOfForks"`
-	NumberOfOpenIssues *uint32    `json:"numberOfOpenIssues"`
-	NumberOfStars      *uint32    `json:"numberOfStars"`
-	NumberOfWatchers   *uint32    `json:"numberOfWatchers"`
-	CreatedAt          *time.Time `json:"createdAt"`
-	UpdatedAt          *time.Time `json:"updatedAt"`
+	Type          string  `json:"type"`
+	Origin        string  `json:"origin"`
+	Source        string  `json:"source"`
+	Fullname      string  `json:"fullname"`
+	Name          string  `json:"name"`
+	Description   *string `json:"description"`
+	DefaultBranch *string `json:"defaultBranch"`
+	Language      *string `json:"language"`
+	// Languages contains the percentage per language, if the provider
+	// reports more than the dominant Language.
+	Languages   map[string]float64 `json:"languages"`
+	HomepageUrl *string            `json:"homepageUrl"`
+	ImageAsset  *string            `json:"imageAsset"`
+	// ImageVariants are square thumbnails of ImageAsset, ordered by size.
+	ImageVariants      []imageVariant `json:"imageVariants"`
+	ProfileUrl         string         `json:"profileUrl"`
+	HttpCloneUrl       *string        `json:"httpCloneUrl"`
+	SshCloneUrl        *string        `json:"sshCloneUrl"`
+	IssuesUrl          *string        `json:"issuesUrl"`
+	WikiUrl            *string        `json:"wikiUrl"`
+	ForksUrl           *string        `json:"forksUrl"`
+	PullRequestsUrl    *string        `json:"pullRequestsUrl"`
+	CreateForkUrl      *string        `json:"createForkUrl"`
+	StarsUrl           *string        `json:"starsUrl"`
+	WatchersUrl        *string        `json:"watchersUrl"`
+	NumberOfForks      *uint32        `json:"numberOfForks"`
+	NumberOfOpenIssues *uint32        `json:"numberOfOpenIssues"`
+	NumberOfStars      *uint32        `json:"numberOfStars"`
+	NumberOfWatchers   *uint32        `json:"numberOfWatchers"`
+	CreatedAt          *time.Time     `json:"createdAt"`
+	UpdatedAt          *time.Time     `json:"updatedAt"`
+	Topics             []string       `json:"topics"`
+	// Contributors are the top contributors, most commits first.
+	Contributors         []contributor `json:"contributors"`
+	NumberOfContributors *uint32       `json:"numberOfContributors"`
+	License              *license      `json:"license"`
+	LatestRelease        *release      `json:"latestRelease"`
+	// NumberOfRecentReleases within the recentReleasesPeriod before the
+	// retrieval, as far as the first page of releases reaches.
+	NumberOfRecentReleases *uint32 `json:"numberOfRecentReleases"`
+	Fork                   bool    `json:"fork"`
+	Archived               bool    `json:"archived"`
+	Featured               bool    `json:"featured"`
+	Hidden                 bool    `json:"hidden"`
+	Category               *string `json:"category"`
+	SortWeight             int     `json:"sortWeight"`
+	// Mirrors of this project at other providers.
+	Mirrors []projectMirror `json:"mirrors"`
+	// OwnCounters are the counters of this project without the ones of its
+	// Mirrors; only present if it has Mirrors.
+	OwnCounters *projectCounters `json:"ownCounters"`
+
+	// mirrorOf is the URL of the repository this project mirrors, if
+	// the provider reports it as a mirror.
+	mirrorOf string
+	// rootCommitResolver returns the hash of the first commit of the default
+	// branch. It is only called if needed because it requires API calls.
+	rootCommitResolver func() (string, error)
+	// fileFetcher is used to read files like the license file if the
+	// provider does not report their content.
+	fileFetcher fileFetcher
+	// releasesLister and tagsLister are used to resolve the LatestRelease.
+	releasesLister releasesLister
+	tagsLister     releasesLister
+	// contributorsLister is used to resolve the Contributors; all of them
+	// are kept in allContributors for the statistics.
+	contributorsLister contributorsLister
+	allContributors    []contributor
+}
+
+// fileFetcher returns the content of the file with the given name in the root
+// of the default branch of a project; found is false if there is no such file.
+type fileFetcher func(name string) (content string, found bool, err error)
+
+// matches returns true if the given reference is either the fullname or
+// "<origin>:<fullname>" of this project.
+func (instance project) matches(reference string) bool {
+	return reference == instance.Fullname || reference == instance.Origin+":"+instance.Fullname
+}
+
+// hasAnyTopic returns true if this project has at least one of the given
+// topics, ignoring the case.
+func (instance project) hasAnyTopic(topics []string) bool {
+	for _, expected := range topics {
+		for _, topic := range instance.Topics {
+			if strings.EqualFold(expected, topic) {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 type projects []project
@@ -140,6 +240,9 @@ type projects []project
 func (instance projects) Len() int      { return len(instance) }
 func (instance projects) Swap(i, j int) { instance[i], instance[j] = instance[j], instance[i] }
 func (instance projects) Less(i, j int) bool {
+	if instance[i].SortWeight != instance[j].SortWeight {
+		return instance[i].SortWeight > instance[j].SortWeight
+	}
 	if instance[i].UpdatedAt == nil && instance[j].UpdatedAt == nil {
 		return false
 	}
@@ -152,48 +255,80 @@ func (instance projects) Less(i, j int) bool {
 	return instance[i].UpdatedAt.After(*instance[j].UpdatedAt)
 }
 
-func (instance projects) clean() (result projects) {
+func (instance projects) clean(rules rules) (result projects) {
+	now := time.Now()
 	result = make(projects, 0, len(instance))
 	for _, v := range instance {
-		if v.Origin == "github" && v.Name == "echocat.org" {
-			continue
+		if rules.includesProject(v, now) {
+			result = append(result, v)
 		}
-		result = append(result, v)
 	}
 	return result
 }
 
 type member struct {
-	Type        string     `json:"type"`
-	Fullname    string     `json:"fullname"`
-	Name        string     `json:"name"`
-	Email       *string    `json:"email"`
-	ImageAsset  string     `json:"imageAsset"`
-	ProfileUrl  string     `json:"profileUrl"`
-	Bio         *string    `json:"bio"`
-	Location    *string    `json:"location"`
-	Company     *string    `json:"company"`
-	HomepageUrl *string    `json:"homepageUrl"`
-	SkypeId     *string    `json:"skypeId"`
-	LinkedinId  *string    `json:"linkedinId"`
-	TwitterId   *string    `json:"twitterId"`
-	CreatedAt   *time.Time `json:"createdAt"`
-	UpdatedAt   *time.Time `json:"updatedAt"`
-}
-
-func (instance members) Len() int           { return len(instance) }
-func (instance members) Swap(i, j int)      { instance[i], instance[j] = instance[j], instance[i] }
-func (instance members) Less(i, j int) bool { return instance[i].Fullname < instance[j].Fullname }
+	Type       string  `json:"type"`
+	Source     string  `json:"source"`
+	Fullname   string  `json:"fullname"`
+	Name       string  `json:"name"`
+	Email      *string `json:"email"`
+	ImageAsset string  `json:"imageAsset"`
+	// ImageVariants are square thumbnails of ImageAsset, ordered by size.
+	ImageVariants []imageVariant `json:"imageVariants"`
+	ProfileUrl    string         `json:"profileUrl"`
+	Bio           *string        `json:"bio"`
+	Location      *string        `json:"location"`
+	Company       *string        `json:"company"`
+	HomepageUrl   *string        `json:"homepageUrl"`
+	SkypeId       *string        `json:"skypeId"`
+	LinkedinId    *string        `json:"linkedinId"`
+	TwitterId     *string        `json:"twitterId"`
+	CreatedAt     *time.Time     `json:"createdAt"`
+	UpdatedAt     *time.Time     `json:"updatedAt"`
+	Featured      bool           `json:"featured"`
+	Hidden        bool           `json:"hidden"`
+	SortWeight    int            `json:"sortWeight"`
+	// EmailVerified is true if the provider guarantees that Email was
+	// verified by the user. It is kept to link the last known accounts of
+	// failing sources again.
+	EmailVerified bool `json:"emailVerified"`
+	// Accounts of this member at all providers.
+	Accounts []memberAccount `json:"accounts"`
+}
+
+// accounts returns Accounts or - if not yet filled - the account of this
+// member itself.
+func (instance member) accounts() []memberAccount {
+	if len(instance.Accounts) > 0 {
+		return instance.Accounts
+	}
+	return []memberAccount{{
+		Type:       instance.Type,
+		Origin:     strings.TrimPrefix(instance.Type, "user:"),
+		Source:     instance.Source,
+		Login:      instance.Name,
+		ProfileUrl: instance.ProfileUrl,
+		ImageAsset: instance.ImageAsset,
+	}}
+}
+
+func (instance members) Len() int      { return len(instance) }
+func (instance members) Swap(i, j int) { instance[i], instance[j] = instance[j], instance[i] }
+func (instance members) Less(i, j int) bool {
+	if instance[i].SortWeight != instance[j].SortWeight {
+		return instance[i].SortWeight > instance[j].SortWeight
+	}
+	return instance[i].Fullname < instance[j].Fullname
+}
 
 type members []member
 
-func (instance members) clean() (result members) {
+func (instance members) clean(rules rules) (result members) {
 	result = make(members, 0, len(instance))
 	for _, v := range instance {
-		if v.Name == "echocat-bot" || v.Name == "echocat" {
-			continue
+		if rules.includesMember(v) {
+			result = append(result, v)
 		}
-		result = append(result, v)
 	}
 	return result
 }
@@ -204,6 +339,7 @@ func (instance member) merge(with ...member) member {
 	for _, in := range with {
 		if result.Email == nil && in.Email != nil {
 			result.Email = pString(*in.Email)
+			result.EmailVerified = in.EmailVerified
 		}
 		if result.Bio == nil && in.Bio != nil {
 			result.Bio = pString(*in.Bio)
@@ -238,4 +374,17 @@ type statistics struct {
 	NumberOfOpenIssues uint32 `json:"numberOfOpenIssues"`
 	NumberOfWatchers   uint32 `json:"numberOfWatchers"`
 	NumberOfForks      uint32 `json:"numberOfForks"`
+	// NumberOfRecentReleases contains the releases of all projects within
+	// the recentReleasesPeriod.
+	NumberOfRecentReleases uint32 `json:"numberOfRecentReleases"`
+	// NumberOfContributors is the number of unique contributors of all
+	// projects, without bots.
+	NumberOfContributors uint32 `json:"numberOfContributors"`
+	// Contributors ranks all contributors by their commits to all projects.
+	Contributors []rankedContributor `json:"contributors"`
+	// Licenses contains the number of projects per SPDX identifier.
+	Licenses map[string]uint32 `json:"licenses"`
+	// Languages contains the percentage per language, averaged over all
+	// projects with languages; every project has the same weight.
+	Languages map[string]float64 `json:"languages"`
 }
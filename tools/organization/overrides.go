@@ -0,0 +1,250 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	log "github.com/echocat/slf4g"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	overridesFile = flag.String("overrides", "", "YAML or JSON file which contains overrides for projects and members which are applied after all sources were retrieved.")
+)
+
+// overrides are applied on top of the retrieved organization. Projects are
+// keyed by either their fullname or "<origin>:<fullname>", members by their
+// name.
+type overrides struct {
+	Projects map[string]projectOverride `yaml:"projects"`
+	Members  map[string]memberOverride  `yaml:"members"`
+
+	file string
+}
+
+type projectOverride struct {
+	Description *string `yaml:"description"`
+	HomepageUrl *string `yaml:"homepageUrl"`
+	Language    *string `yaml:"language"`
+	// ImageAsset is either a URL or a path to a local file (relative to the
+	// overrides file) which will be imported as asset.
+	ImageAsset *string `yaml:"imageAsset"`
+	Featured   *bool   `yaml:"featured"`
+	Hidden     *bool   `yaml:"hidden"`
+	Category   *string `yaml:"category"`
+	SortWeight *int    `yaml:"sortWeight"`
+}
+
+type memberOverride struct {
+	Fullname    *string `yaml:"fullname"`
+	Bio         *string `yaml:"bio"`
+	Location    *string `yaml:"location"`
+	Company     *string `yaml:"company"`
+	HomepageUrl *string `yaml:"homepageUrl"`
+	// ImageAsset is either a URL or a path to a local file (relative to the
+	// overrides file) which will be imported as asset.
+	ImageAsset *string `yaml:"imageAsset"`
+	Featured   *bool   `yaml:"featured"`
+	Hidden     *bool   `yaml:"hidden"`
+	SortWeight *int    `yaml:"sortWeight"`
+}
+
+// loadOverrides returns nil if no overrides file was configured.
+func loadOverrides() (*overrides, error) {
+	if *overridesFile == "" {
+		return nil, nil
+	}
+	return loadOverridesFrom(*overridesFile)
+}
+
+func loadOverridesFrom(file string) (*overrides, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read overrides '%s': %w", file, err)
+	}
+
+	result := overrides{file: file}
+	if err := yaml.Unmarshal(b, &result); err != nil {
+		return nil, fmt.Errorf("cannot parse overrides '%s': %w", file, err)
+	}
+
+	var raw map[string]map[string]map[string]interface{}
+	if err := yaml.Unmarshal(b, &raw); err != nil {
+		return nil, fmt.Errorf("cannot parse overrides '%s': %w", file, err)
+	}
+	result.reportUnknownKeys(raw)
+
+	return &result, nil
+}
+
+func (instance overrides) reportUnknownKeys(raw map[string]map[string]map[string]interface{}) {
+	known := map[string]map[string]bool{
+		"projects": yamlKeysOf(projectOverride{}),
+		"members":  yamlKeysOf(memberOverride{}),
+	}
+	for section, entries := range raw {
+		knownKeys, ok := known[section]
+		if !ok {
+			log.With("file", instance.file).
+				With("section", section).
+				Warn("Overrides contain an unknown section; it will be ignored.")
+			continue
+		}
+		for reference, fields := range entries {
+			for key := range fields {
+				if !knownKeys[key] {
+					log.With("file", instance.file).
+						With("section", section).
+						With("reference", reference).
+						With("key", key).
+						Warn("Override contains an unknown key; it will be ignored.")
+				}
+			}
+		}
+	}
+}
+
+func (instance overrides) apply(to organization, assetClient *assetClient) (organization, error) {
+	result := to
+	result.Projects = append(projects{}, to.Projects...)
+	result.Members = append(members{}, to.Members...)
+
+	for _, reference := range sortedKeys(instance.Projects) {
+		override := instance.Projects[reference]
+		matched := false
+		for i, candidate := range result.Projects {
+			if candidate.matches(reference) {
+				if err := instance.applyToProject(override, &result.Projects[i], assetClient); err != nil {
+					return organization{}, fmt.Errorf("cannot apply override of project '%s': %w", reference, err)
+				}
+				matched = true
+			}
+		}
+		if !matched {
+			log.With("file", instance.file).
+				With("project", reference).
+				Warn("Override does not match any project; it might be stale.")
+		}
+	}
+
+	for _, reference := range sortedKeys(instance.Members) {
+		override := instance.Members[reference]
+		matched := false
+		for i, candidate := range result.Members {
+			if candidate.Name == reference {
+				if err := instance.applyToMember(override, &result.Members[i], assetClient); err != nil {
+					return organization{}, fmt.Errorf("cannot apply override of member '%s': %w", reference, err)
+				}
+				matched = true
+			}
+		}
+		if !matched {
+			log.With("file", instance.file).
+				With("member", reference).
+				Warn("Override does not match any member; it might be stale.")
+		}
+	}
+
+	result.align()
+	return result, nil
+}
+
+func (instance overrides) applyToProject(override projectOverride, target *project, assetClient *assetClient) error {
+	if override.Description != nil {
+		target.Description = pNonEmptyString(*override.Description)
+	}
+	if override.HomepageUrl != nil {
+		target.HomepageUrl = pNonEmptyString(*override.HomepageUrl)
+	}
+	if override.Language != nil {
+		target.Language = pNonEmptyString(canonicalLanguage(*override.Language))
+	}
+	if override.ImageAsset != nil {
+		if imageAsset, err := instance.retrieveImage(projectAssetOwner(target.Origin, target.Fullname), *override.ImageAsset, assetClient); err != nil {
+			return err
+		} else {
+			target.ImageAsset = pNonEmptyString(imageAsset)
+		}
+	}
+	if override.Featured != nil {
+		target.Featured = *override.Featured
+	}
+	if override.Hidden != nil {
+		target.Hidden = *override.Hidden
+	}
+	if override.Category != nil {
+		target.Category = pNonEmptyString(*override.Category)
+	}
+	if override.SortWeight != nil {
+		target.SortWeight = *override.SortWeight
+	}
+	return nil
+}
+
+func (instance overrides) applyToMember(override memberOverride, target *member, assetClient *assetClient) error {
+	if override.Fullname != nil {
+		target.Fullname = *override.Fullname
+	}
+	if override.Bio != nil {
+		target.Bio = pNonEmptyString(*override.Bio)
+	}
+	if override.Location != nil {
+		target.Location = pNonEmptyString(*override.Location)
+	}
+	if override.Company != nil {
+		target.Company = pNonEmptyString(*override.Company)
+	}
+	if override.HomepageUrl != nil {
+		target.HomepageUrl = pNonEmptyString(*override.HomepageUrl)
+	}
+	if override.ImageAsset != nil {
+		if imageAsset, err := instance.retrieveImage(memberAssetOwner(target.Name), *override.ImageAsset, assetClient); err != nil {
+			return err
+		} else {
+			target.ImageAsset = imageAsset
+		}
+	}
+	if override.Featured != nil {
+		target.Featured = *override.Featured
+	}
+	if override.Hidden != nil {
+		target.Hidden = *override.Hidden
+	}
+	if override.SortWeight != nil {
+		target.SortWeight = *override.SortWeight
+	}
+	return nil
+}
+
+func (instance overrides) retrieveImage(owner assetOwner, location string, assetClient *assetClient) (string, error) {
+	if location == "" {
+		return "", nil
+	}
+	return assetClient.retrieveFromLocation(owner, location, filepath.Dir(instance.file))
+}
+
+// yamlKeysOf returns the yaml keys of all fields of the given struct.
+func yamlKeysOf(of interface{}) map[string]bool {
+	result := map[string]bool{}
+	t := reflect.TypeOf(of)
+	for i := 0; i < t.NumField(); i++ {
+		if tag := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]; tag != "" && tag != "-" {
+			result[tag] = true
+		}
+	}
+	return result
+}
+
+func sortedKeys[V any](of map[string]V) []string {
+	result := make([]string, 0, len(of))
+	for key := range of {
+		result = append(result, key)
+	}
+	sort.Strings(result)
+	return result
+}
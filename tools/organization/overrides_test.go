@@ -0,0 +1,64 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type overridesSuite struct{}
+
+var _ = Suite(&overridesSuite{})
+
+func (s *overridesSuite) TestApply(c *C) {
+	*assetsFolder = c.MkDir()
+	file := filepath.Join(c.MkDir(), "overrides.yml")
+	c.Assert(os.WriteFile(file, []byte(`
+projects:
+  github:acme/foo:
+    description: Better description
+    featured: true
+    sortWeight: 10
+    category: code
+  acme/bar:
+    hidden: true
+  acme/unknown:
+    description: stale
+members:
+  jdoe:
+    fullname: John Doe
+    bio: ""
+    unknownKey: foo
+`), 0644), IsNil)
+
+	instance, err := loadOverridesFrom(file)
+	c.Assert(err, IsNil)
+
+	actual, err := instance.apply(organization{
+		Projects: projects{
+			{Origin: "github", Fullname: "acme/bar", Name: "bar", NumberOfStars: pUint32(5), UpdatedAt: pTime(time.Unix(1, 0))},
+			{Origin: "github", Fullname: "acme/foo", Name: "foo", Description: pString("bad"), NumberOfStars: pUint32(1), UpdatedAt: pTime(time.Unix(2, 0))},
+			{Origin: "gitlab", Fullname: "acme/foo", Name: "foo", Description: pString("other"), UpdatedAt: pTime(time.Unix(3, 0))},
+		},
+		Members: members{
+			{Name: "jdoe", Fullname: "jdoe", Bio: pString("old")},
+		},
+	}, newAssetClient(http.DefaultTransport))
+	c.Assert(err, IsNil)
+
+	c.Assert(actual.Projects[0].Origin, Equals, "github")
+	c.Assert(actual.Projects[0].Name, Equals, "foo")
+	c.Assert(*actual.Projects[0].Description, Equals, "Better description")
+	c.Assert(actual.Projects[0].Featured, Equals, true)
+	c.Assert(*actual.Projects[0].Category, Equals, "code")
+	c.Assert(*actual.Projects[1].Description, Equals, "other")
+	c.Assert(actual.Projects[2].Hidden, Equals, true)
+	c.Assert(actual.Statistics.NumberOfProjects, Equals, uint32(2))
+	c.Assert(actual.Statistics.NumberOfStars, Equals, uint32(1))
+
+	c.Assert(actual.Members[0].Fullname, Equals, "John Doe")
+	c.Assert(actual.Members[0].Bio, IsNil)
+}
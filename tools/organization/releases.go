@@ -0,0 +1,272 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	log "github.com/echocat/slf4g"
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+)
+
+var (
+	goProxyUrl = flag.String("go-proxy", "https://proxy.golang.org", "Go module proxy to cross-check the latest release of Go projects with. Empty disables it.")
+)
+
+// recentReleasesPeriod is the period in which releases count as recent.
+const recentReleasesPeriod = 90 * 24 * time.Hour
+
+type release struct {
+	Tag         string     `json:"tag"`
+	Name        string     `json:"name"`
+	PublishedAt *time.Time `json:"publishedAt"`
+	Url         string     `json:"url"`
+	Prerelease  bool       `json:"prerelease"`
+}
+
+// releasesLister returns the first page of releases of a project, newest
+// first and without drafts. It is also used to list the tags of a project,
+// which are returned as releases with the tag as name.
+type releasesLister func() ([]release, error)
+
+// resolveReleases sets the LatestRelease and NumberOfRecentReleases of every
+// project. Projects without releases fall back to their newest semver tag; the
+// releases of Go projects are cross-checked with the versions of their module
+// if proxy is not nil.
+func resolveReleases(input projects, proxy *goModuleProxy) projects {
+	now := time.Now()
+	result, _ := mapConcurrently(input, func(candidate project) (project, error) {
+		l := log.With("project", candidate.Origin+":"+candidate.Fullname)
+		if candidate.releasesLister != nil {
+			if releases, err := candidate.releasesLister(); err != nil {
+				l.WithError(err).
+					Warn("Cannot list releases; the latest release stays unknown.")
+			} else {
+				candidate.applyReleases(releases, now)
+			}
+		}
+		if candidate.LatestRelease == nil && candidate.tagsLister != nil {
+			if tags, err := candidate.tagsLister(); err != nil {
+				l.WithError(err).
+					Warn("Cannot list tags; the latest release stays unknown.")
+			} else {
+				candidate.applyReleases(semverReleasesOf(tags), now)
+			}
+		}
+		if proxy != nil && candidate.isGo() {
+			candidate.LatestRelease = proxy.crossCheck(candidate)
+		}
+		return candidate, nil
+	})
+	return result
+}
+
+// applyReleases expects releases to be ordered newest first. The newest
+// release which is not a prerelease wins over newer prereleases.
+func (instance *project) applyReleases(releases []release, now time.Time) {
+	if len(releases) == 0 {
+		return
+	}
+	latest := releases[0]
+	for _, candidate := range releases {
+		if !candidate.Prerelease {
+			latest = candidate
+			break
+		}
+	}
+	var recent uint32
+	for _, candidate := range releases {
+		if candidate.PublishedAt != nil && now.Sub(*candidate.PublishedAt) <= recentReleasesPeriod {
+			recent++
+		}
+	}
+	instance.LatestRelease = &latest
+	instance.NumberOfRecentReleases = pUint32(recent)
+}
+
+func (instance project) isGo() bool {
+	return instance.Language != nil && canonicalLanguage(*instance.Language) == canonicalLanguage("go")
+}
+
+// semverOf returns the canonical semantic version of the given tag or an
+// empty string if the tag is not a semantic version. The "v" prefix is
+// optional.
+func semverOf(tag string) string {
+	if semver.IsValid(tag) {
+		return semver.Canonical(tag)
+	}
+	if semver.IsValid("v" + tag) {
+		return semver.Canonical("v" + tag)
+	}
+	return ""
+}
+
+// semverReleasesOf returns all tags which are semantic versions, ordered by
+// their version, newest first.
+func semverReleasesOf(tags []release) []release {
+	var result []release
+	for _, tag := range tags {
+		version := semverOf(tag.Tag)
+		if version == "" {
+			continue
+		}
+		tag.Prerelease = semver.Prerelease(version) != ""
+		result = append(result, tag)
+	}
+	sortReleasesBySemver(result)
+	return result
+}
+
+func sortReleasesBySemver(releases []release) {
+	// Insertion sort keeps tags of the same version in the reported order.
+	for i := 1; i < len(releases); i++ {
+		for j := i; j > 0 && semver.Compare(semverOf(releases[j-1].Tag), semverOf(releases[j].Tag)) < 0; j-- {
+			releases[j-1], releases[j] = releases[j], releases[j-1]
+		}
+	}
+}
+
+// goModuleProxy lists the versions of Go modules with the GOPROXY protocol.
+type goModuleProxy struct {
+	url    string
+	client *http.Client
+}
+
+// newGoModuleProxy returns nil if no --go-proxy was configured.
+func newGoModuleProxy(transport http.RoundTripper) *goModuleProxy {
+	if *goProxyUrl == "" {
+		return nil
+	}
+	return &goModuleProxy{
+		url:    strings.TrimSuffix(*goProxyUrl, "/"),
+		client: &http.Client{Transport: transport},
+	}
+}
+
+// crossCheck returns the latest release of the given Go project. If it has
+// no release, the latest version of its module is used instead. A release
+// which differs from the latest module version is reported, because it was
+// most likely not tagged the way Go expects it.
+func (instance *goModuleProxy) crossCheck(candidate project) *release {
+	l := log.With("project", candidate.Origin+":"+candidate.Fullname)
+	path, err := instance.modulePathOf(candidate)
+	if err != nil {
+		l.WithError(err).
+			Warn("Cannot resolve the Go module; the release is not cross-checked.")
+		return candidate.LatestRelease
+	}
+	if path == "" {
+		return candidate.LatestRelease
+	}
+	l = l.With("module", path)
+	latest, err := instance.latestVersionOf(path)
+	if err != nil {
+		l.WithError(err).
+			Warn("Cannot list versions of the Go module; the release is not cross-checked.")
+		return candidate.LatestRelease
+	}
+	if latest == nil {
+		return candidate.LatestRelease
+	}
+	if candidate.LatestRelease == nil {
+		return latest
+	}
+	if expected := semverOf(candidate.LatestRelease.Tag); expected != "" && semver.Compare(expected, semverOf(latest.Tag)) != 0 {
+		l.With("release", candidate.LatestRelease.Tag).
+			With("version", latest.Tag).
+			Warn("Latest release differs from the latest version of the Go module.")
+	}
+	return candidate.LatestRelease
+}
+
+func (instance *goModuleProxy) modulePathOf(candidate project) (string, error) {
+	if candidate.fileFetcher == nil {
+		return "", nil
+	}
+	content, found, err := candidate.fileFetcher("go.mod")
+	if err != nil || !found {
+		return "", err
+	}
+	return modfile.ModulePath([]byte(content)), nil
+}
+
+// latestVersionOf returns the latest version of the given module or nil if
+// the proxy does not know any version of it.
+func (instance *goModuleProxy) latestVersionOf(path string) (*release, error) {
+	escapedPath, err := module.EscapePath(path)
+	if err != nil {
+		return nil, fmt.Errorf("illegal module path '%s': %w", path, err)
+	}
+
+	resp, err := instance.client.Get(instance.url + "/" + escapedPath + "/@v/list")
+	if err != nil {
+		return nil, fmt.Errorf("cannot list versions of module '%s': %w", path, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusGone:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("cannot list versions of module '%s': unexpected status %d", path, resp.StatusCode)
+	}
+
+	var versions []release
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if version := strings.TrimSpace(scanner.Text()); version != "" {
+			versions = append(versions, release{Tag: version})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("cannot list versions of module '%s': %w", path, err)
+	}
+	versions = semverReleasesOf(versions)
+	if len(versions) == 0 {
+		return nil, nil
+	}
+	latest := versions[0]
+	for _, candidate := range versions {
+		if !candidate.Prerelease {
+			latest = candidate
+			break
+		}
+	}
+
+	publishedAt, err := instance.timeOf(escapedPath, latest.Tag)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get version %s of module '%s': %w", latest.Tag, path, err)
+	}
+	latest.Name = latest.Tag
+	latest.PublishedAt = publishedAt
+	latest.Url = "https://pkg.go.dev/" + path + "@" + latest.Tag
+	return &latest, nil
+}
+
+func (instance *goModuleProxy) timeOf(escapedPath, version string) (*time.Time, error) {
+	escapedVersion, err := module.EscapeVersion(version)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := instance.client.Get(instance.url + "/" + escapedPath + "/@v/" + escapedVersion + ".info")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	var info struct {
+		Time *time.Time `json:"Time"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return nil, err
+	}
+	return info.Time, nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type releasesSuite struct{}
+
+var _ = Suite(&releasesSuite{})
+
+func (s *releasesSuite) TestCountsRecentReleases(c *C) {
+	now := time.Now()
+	instance := project{
+		releasesLister: func() ([]release, error) {
+			return []release{
+				{Tag: "v2.0.0-rc.1", PublishedAt: pTime(now.Add(-24 * time.Hour)), Prerelease: true},
+				{Tag: "v1.1.0", PublishedAt: pTime(now.Add(-30 * 24 * time.Hour))},
+				{Tag: "v1.0.0", PublishedAt: pTime(now.Add(-100 * 24 * time.Hour))},
+			}, nil
+		},
+	}
+
+	actual := resolveReleases(projects{instance}, nil)
+
+	c.Assert(actual[0].LatestRelease.Tag, Equals, "v1.1.0")
+	c.Assert(*actual[0].NumberOfRecentReleases, Equals, uint32(2))
+
+	org := organization{Projects: actual}
+	org.align()
+	c.Assert(org.Statistics.NumberOfRecentReleases, Equals, uint32(2))
+}
+
+func (s *releasesSuite) TestSemverReleasesOf(c *C) {
+	actual := semverReleasesOf([]release{
+		{Tag: "latest"},
+		{Tag: "1.9.0"},
+		{Tag: "v1.10.0-beta.1"},
+		{Tag: "v1.10.0"},
+		{Tag: "v1.2"},
+	})
+
+	var tags []string
+	for _, candidate := range actual {
+		tags = append(tags, candidate.Tag)
+	}
+	c.Assert(tags, DeepEquals, []string{"v1.10.0", "v1.10.0-beta.1", "1.9.0", "v1.2"})
+	c.Assert(actual[1].Prerelease, Equals, true)
+}
+
+func (s *releasesSuite) TestCrossChecksGoModules(c *C) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/github.com/acme/!foo/v2/@v/list":
+			_, _ = w.Write([]byte("v2.0.0\nv2.1.0\nv2.2.0-rc.1\n"))
+		case "/github.com/acme/!foo/v2/@v/v2.1.0.info":
+			_, _ = w.Write([]byte(`{"Version":"v2.1.0","Time":"2023-04-01T10:00:00Z"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	*goProxyUrl = server.URL + "/"
+	defer func() { *goProxyUrl = "https://proxy.golang.org" }()
+	proxy := newGoModuleProxy(http.DefaultTransport)
+
+	goMod := func(name string) (string, bool, error) {
+		if name != "go.mod" {
+			return "", false, nil
+		}
+		return "module github.com/acme/Foo/v2\n\ngo 1.21\n", true, nil
+	}
+	released := release{Tag: "v2.0.0", Name: "2.0.0"}
+
+	actual := resolveReleases(projects{{
+		Name:        "without-release",
+		Language:    pString("Go"),
+		fileFetcher: goMod,
+	}, {
+		Name:           "with-release",
+		Language:       pString("Go"),
+		fileFetcher:    goMod,
+		releasesLister: func() ([]release, error) { return []release{released}, nil },
+	}, {
+		Name:        "not-go",
+		Language:    pString("Java"),
+		fileFetcher: goMod,
+	}}, proxy)
+
+	c.Assert(*actual[0].LatestRelease, DeepEquals, release{
+		Tag:         "v2.1.0",
+		Name:        "v2.1.0",
+		PublishedAt: pTime(time.Date(2023, time.April, 1, 10, 0, 0, 0, time.UTC)),
+		Url:         "https://pkg.go.dev/github.com/acme/Foo/v2@v2.1.0",
+	})
+	// Differences are only reported.
+	c.Assert(*actual[1].LatestRelease, DeepEquals, released)
+	c.Assert(actual[2].LatestRelease, IsNil)
+}
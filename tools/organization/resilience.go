@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	log "github.com/echocat/slf4g"
+)
+
+var (
+	resilient = flag.Bool("resilient", false, "If a source fails its last known data is taken from the previous output instead of failing the whole run. Such a run exits with code 3.")
+)
+
+// exitCodeDegraded signals that the output was written, but at least one
+// source is not up-to-date.
+const exitCodeDegraded = 3
+
+type sourceState string
+
+const (
+	// sourceStateFresh means the source was retrieved by this run.
+	sourceStateFresh = sourceState("fresh")
+	// sourceStateStale means the source failed and its data is taken from
+	// the previous run.
+	sourceStateStale = sourceState("stale")
+	// sourceStateFailed means the source failed and there was no previous
+	// data of it.
+	sourceStateFailed = sourceState("failed")
+)
+
+type sourceStatus struct {
+	Name  string      `json:"name"`
+	Type  string      `json:"type"`
+	State sourceState `json:"state"`
+	// RetrievedAt is the time when the data of this source was retrieved the
+	// last time successfully; for stale sources this is in a previous run.
+	RetrievedAt *time.Time `json:"retrievedAt"`
+	Error       *string    `json:"error"`
+}
+
+// degraded returns true if at least one source is not fresh.
+func (instance organization) degraded() bool {
+	for _, status := range instance.Sources {
+		if status.State != sourceStateFresh {
+			return true
+		}
+	}
+	return false
+}
+
+// assignSource marks all entities as retrieved from the given source.
+func (instance *organization) assignSource(source string) {
+	for i := range instance.Projects {
+		instance.Projects[i].Source = source
+	}
+	for i := range instance.Members {
+		instance.Members[i].Source = source
+	}
+}
+
+// loadPreviousOrganization returns an empty organization if the file does not
+// exist, for example on the first run.
+func loadPreviousOrganization(file string) (*organization, error) {
+	f, err := os.Open(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return &organization{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot open previous organization '%s': %w", file, err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	var result organization
+	if err := json.NewDecoder(f).Decode(&result); err != nil {
+		return nil, fmt.Errorf("cannot decode previous organization '%s': %w", file, err)
+	}
+	return &result, nil
+}
+
+// fallbackFor returns all entities of this (previous) organization which
+// were retrieved from the given source, including the mirrors which were
+// collapsed into projects of other sources and the accounts which were
+// linked to members of other sources. Image assets which no longer exist are
+// dropped.
+func (instance organization) fallbackFor(delegate compoundClientDelegate, cause error, assetClient *assetClient) (organization, sourceStatus) {
+	status := sourceStatus{
+		Name:  delegate.name,
+		Type:  delegate.sourceType,
+		State: sourceStateFailed,
+		Error: pString(cause.Error()),
+	}
+	for _, candidate := range instance.Sources {
+		if candidate.Name == delegate.name {
+			status.RetrievedAt = candidate.RetrievedAt
+		}
+	}
+
+	// The projects were collapsed with their mirrors of other sources; these
+	// are collapsed again with the fresh data, so they are re-expanded here.
+	var result organization
+	for _, candidate := range instance.Projects {
+		for _, mirror := range candidate.Mirrors {
+			if mirror.Source == delegate.name {
+				result.Projects = append(result.Projects, mirror.toProject(candidate))
+			}
+		}
+		if candidate.Source != delegate.name {
+			continue
+		}
+		candidate = candidate.unmerged()
+		if candidate.ImageAsset != nil && !assetClient.exists(*candidate.ImageAsset) {
+			candidate.ImageAsset = nil
+		}
+		result.Projects = append(result.Projects, candidate)
+	}
+	// The same applies to members with accounts of several sources.
+	for _, candidate := range instance.Members {
+		for _, account := range candidate.accounts() {
+			if account.Source == "" {
+				// Written before accounts recorded their source.
+				account.Source = candidate.Source
+			}
+			if account.Source != delegate.name {
+				continue
+			}
+			if !assetClient.exists(account.ImageAsset) {
+				account.ImageAsset = ""
+			}
+			result.Members = append(result.Members, account.toMember(candidate))
+		}
+	}
+
+	if status.RetrievedAt != nil || len(result.Projects) > 0 || len(result.Members) > 0 {
+		status.State = sourceStateStale
+	}
+	log.With("source", delegate.name).
+		With("projects", len(result.Projects)).
+		With("members", len(result.Members)).
+		With("retrievedAt", status.RetrievedAt).
+		Warn("Using last known data of source.")
+
+	result.align()
+	return result, status
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type resilienceSuite struct{}
+
+var _ = Suite(&resilienceSuite{})
+
+type clientFunc func() (organization, error)
+
+func (instance clientFunc) retrieveOrganization() (organization, error) {
+	return instance()
+}
+
+func (s *resilienceSuite) TestUsesPreviousDataOfFailingSource(c *C) {
+	*assetsFolder = c.MkDir()
+	c.Assert(os.WriteFile(filepath.Join(*assetsFolder, "present.png"), []byte("png"), 0644), IsNil)
+
+	retrievedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	previousFile := filepath.Join(c.MkDir(), "organization.json")
+	c.Assert(organization{
+		Projects: projects{
+			{Origin: "github", Fullname: "echocat/old", Name: "old", Source: "github:echocat"},
+			{Origin: "gitlab", Fullname: "foo", Name: "foo", Source: "gitlab:123", ImageAsset: pString("present.png"), NumberOfStars: pUint32(3)},
+			{Origin: "gitlab", Fullname: "bar", Name: "bar", Source: "gitlab:123", ImageAsset: pString("missing.png")},
+		},
+		Members: members{
+			{Type: "user:gitlab", Name: "jdoe", Fullname: "John Doe", Source: "gitlab:123", ImageAsset: "missing.png"},
+		},
+		Sources: []sourceStatus{
+			{Name: "gitlab:123", Type: "gitlab", State: sourceStateFresh, RetrievedAt: &retrievedAt},
+		},
+	}.save(previousFile), IsNil)
+
+	instance := &compoundClient{
+		delegates: []compoundClientDelegate{{
+			name:       "github:echocat",
+			sourceType: "github",
+			client: clientFunc(func() (organization, error) {
+				return organization{Projects: projects{{Origin: "github", Fullname: "echocat/new", Name: "new"}}}, nil
+			}),
+		}, {
+			name:       "gitlab:123",
+			sourceType: "gitlab",
+			client: clientFunc(func() (organization, error) {
+				return organization{}, errors.New("expected")
+			}),
+		}},
+		assetClient:  newAssetClient(nil),
+		previousFile: previousFile,
+	}
+
+	actual, err := instance.retrieveOrganization()
+	c.Assert(err, IsNil)
+	c.Assert(actual.degraded(), Equals, true)
+
+	c.Assert(actual.Projects, HasLen, 3)
+	bySource := map[string][]string{}
+	for _, p := range actual.Projects {
+		bySource[p.Source] = append(bySource[p.Source], p.Fullname)
+		if p.Fullname == "foo" {
+			c.Assert(*p.ImageAsset, Equals, "present.png")
+		}
+		if p.Fullname == "bar" {
+			c.Assert(p.ImageAsset, IsNil)
+		}
+	}
+	c.Assert(bySource["github:echocat"], DeepEquals, []string{"echocat/new"})
+	c.Assert(bySource["gitlab:123"], HasLen, 2)
+	c.Assert(actual.Members, HasLen, 1)
+	c.Assert(actual.Members[0].ImageAsset, Equals, "")
+	c.Assert(actual.Statistics.NumberOfStars, Equals, uint32(3))
+
+	c.Assert(actual.Sources, HasLen, 2)
+	c.Assert(actual.Sources[0].State, Equals, sourceStateFresh)
+	c.Assert(actual.Sources[1].State, Equals, sourceStateStale)
+	c.Assert(actual.Sources[1].RetrievedAt.Equal(retrievedAt), Equals, true)
+	c.Assert(*actual.Sources[1].Error, Equals, "expected")
+}
+
+func (s *resilienceSuite) TestFailsWithoutPreviousFile(c *C) {
+	instance := &compoundClient{
+		delegates: []compoundClientDelegate{{
+			name: "gitlab:123",
+			client: clientFunc(func() (organization, error) {
+				return organization{}, errors.New("expected")
+			}),
+		}},
+	}
+
+	_, err := instance.retrieveOrganization()
+	c.Assert(err, ErrorMatches, "cannot retrieve source 'gitlab:123': expected")
+}
+
+func (s *resilienceSuite) TestFailedIfThereIsNoPreviousData(c *C) {
+	instance := &compoundClient{
+		delegates: []compoundClientDelegate{{
+			name: "gitlab:123",
+			client: clientFunc(func() (organization, error) {
+				return organization{}, errors.New("expected")
+			}),
+		}},
+		assetClient:  newAssetClient(nil),
+		previousFile: filepath.Join(c.MkDir(), "does-not-exist.json"),
+	}
+
+	actual, err := instance.retrieveOrganization()
+	c.Assert(err, IsNil)
+	c.Assert(actual.Sources[0].State, Equals, sourceStateFailed)
+	c.Assert(actual.degraded(), Equals, true)
+}
+
+func (s *resilienceSuite) mirroredProjects() (primary, mirror project) {
+	return project{
+		Origin: "github", Fullname: "echocat/foo", Name: "foo", Source: "github:echocat",
+		ProfileUrl: "https://github.com/echocat/foo", NumberOfStars: pUint32(10),
+	}, project{
+		Origin: "gitlab", Fullname: "foo", Name: "foo", Source: "gitlab:123",
+		ProfileUrl: "https://gitlab.com/foo", NumberOfStars: pUint32(2),
+		mirrorOf: "https://github.com/echocat/foo.git",
+	}
+}
+
+func (s *resilienceSuite) retrieveMirrorsWithFailing(c *C, failing string) organization {
+	*assetsFolder = c.MkDir()
+	primary, mirror := s.mirroredProjects()
+
+	previous := organization{Projects: mirrors{}.collapse(projects{primary, mirror})}
+	previousFile := filepath.Join(c.MkDir(), "organization.json")
+	c.Assert(previous.save(previousFile), IsNil)
+
+	delegate := func(name string, fresh project) compoundClientDelegate {
+		return compoundClientDelegate{name: name, client: clientFunc(func() (organization, error) {
+			if name == failing {
+				return organization{}, errors.New("expected")
+			}
+			return organization{Projects: projects{fresh}}, nil
+		})}
+	}
+	instance := &compoundClient{
+		delegates:    []compoundClientDelegate{delegate("github:echocat", primary), delegate("gitlab:123", mirror)},
+		assetClient:  newAssetClient(nil),
+		previousFile: previousFile,
+	}
+
+	actual, err := instance.retrieveOrganization()
+	c.Assert(err, IsNil)
+	c.Assert(actual.Projects, HasLen, 1)
+	c.Assert(actual.Projects[0].Fullname, Equals, "echocat/foo")
+	c.Assert(actual.Projects[0].Mirrors, HasLen, 1)
+	c.Assert(actual.Projects[0].Mirrors[0].Source, Equals, "gitlab:123")
+	c.Assert(*actual.Projects[0].NumberOfStars, Equals, uint32(12))
+	c.Assert(*actual.Projects[0].OwnCounters.NumberOfStars, Equals, uint32(10))
+	return actual
+}
+
+func (s *resilienceSuite) TestCollapsesLastKnownPrimaryWithFreshMirror(c *C) {
+	actual := s.retrieveMirrorsWithFailing(c, "github:echocat")
+
+	c.Assert(actual.Sources[0].State, Equals, sourceStateStale)
+	c.Assert(actual.Sources[1].State, Equals, sourceStateFresh)
+}
+
+func (s *resilienceSuite) TestKeepsLastKnownMirrorOfFreshPrimary(c *C) {
+	actual := s.retrieveMirrorsWithFailing(c, "gitlab:123")
+
+	c.Assert(actual.Sources[0].State, Equals, sourceStateFresh)
+	c.Assert(actual.Sources[1].State, Equals, sourceStateStale)
+	c.Assert(actual.Projects[0].Mirrors[0].ProfileUrl, Equals, "https://gitlab.com/foo")
+}
+
+func (s *resilienceSuite) retrieveMembersWithFailing(c *C, identities identities, failing string) organization {
+	*assetsFolder = c.MkDir()
+	atGithub := member{
+		Type: "user:github", Name: "jdoe", Fullname: "John Doe", ProfileUrl: "https://github.com/jdoe",
+		Email: pString("jdoe@example.org"), EmailVerified: true,
+	}
+	atGitlab := member{
+		Type: "user:gitlab", Name: "john.doe", Fullname: "john.doe", ProfileUrl: "https://gitlab.com/john.doe",
+		Email: pString("jdoe@example.org"), EmailVerified: true,
+	}
+
+	previousGithub, previousGitlab := atGithub, atGitlab
+	previousGithub.Source, previousGitlab.Source = "github:echocat", "gitlab:123"
+	previous := organization{Members: identities.resolve(members{previousGithub, previousGitlab})}
+	c.Assert(previous.Members, HasLen, 1)
+	previousFile := filepath.Join(c.MkDir(), "organization.json")
+	c.Assert(previous.save(previousFile), IsNil)
+
+	delegate := func(name string, fresh member) compoundClientDelegate {
+		return compoundClientDelegate{name: name, client: clientFunc(func() (organization, error) {
+			if name == failing {
+				return organization{}, errors.New("expected")
+			}
+			return organization{Members: members{fresh}}, nil
+		})}
+	}
+	instance := &compoundClient{
+		delegates:    []compoundClientDelegate{delegate("github:echocat", atGithub), delegate("gitlab:123", atGitlab)},
+		identities:   identities,
+		assetClient:  newAssetClient(nil),
+		previousFile: previousFile,
+	}
+
+	actual, err := instance.retrieveOrganization()
+	c.Assert(err, IsNil)
+	c.Assert(actual.Members, HasLen, 1)
+	c.Assert(actual.Members[0].Fullname, Equals, "John Doe")
+	c.Assert(actual.Members[0].Accounts, DeepEquals, []memberAccount{
+		{Type: "user:github", Origin: "github", Source: "github:echocat", Login: "jdoe", ProfileUrl: "https://github.com/jdoe"},
+		{Type: "user:gitlab", Origin: "gitlab", Source: "gitlab:123", Login: "john.doe", ProfileUrl: "https://gitlab.com/john.doe"},
+	})
+	return actual
+}
+
+func (s *resilienceSuite) TestLinksLastKnownAccountsOfAliasWithFreshOnes(c *C) {
+	aliased := identities{
+		Aliases:             map[string][]string{"jdoe": {"github:jdoe", "gitlab:john.doe"}},
+		MatchVerifiedEmails: pBool(false),
+	}
+
+	actual := s.retrieveMembersWithFailing(c, aliased, "github:echocat")
+	c.Assert(actual.Sources[0].State, Equals, sourceStateStale)
+	c.Assert(actual.Members[0].Source, Equals, "github:echocat")
+
+	actual = s.retrieveMembersWithFailing(c, aliased, "gitlab:123")
+	c.Assert(actual.Sources[1].State, Equals, sourceStateStale)
+	c.Assert(actual.Members[0].Source, Equals, "github:echocat")
+}
+
+func (s *resilienceSuite) TestLinksLastKnownAccountsByVerifiedEmail(c *C) {
+	s.retrieveMembersWithFailing(c, identities{}, "github:echocat")
+	s.retrieveMembersWithFailing(c, identities{}, "gitlab:123")
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// restClient is a minimal JSON REST client for providers which do not have
+// a dedicated client library.
+type restClient struct {
+	baseUrl string
+	headers http.Header
+	client  *http.Client
+}
+
+func newRestClient(baseUrl string, headers http.Header, transport http.RoundTripper) *restClient {
+	return &restClient{
+		baseUrl: strings.TrimSuffix(baseUrl, "/"),
+		headers: headers,
+		client:  &http.Client{Transport: transport},
+	}
+}
+
+// get requests the given path (relative to the baseUrl or absolute) and
+// decodes the JSON response body into target.
+func (instance *restClient) get(ctx context.Context, path string, query url.Values, target interface{}) (resp *http.Response, err error) {
+	u := path
+	if !strings.HasPrefix(path, "http://") && !strings.HasPrefix(path, "https://") {
+		u = instance.baseUrl + "/" + strings.TrimPrefix(path, "/")
+	}
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create request for '%s': %w", u, err)
+	}
+	for key, values := range instance.headers {
+		req.Header[key] = values
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err = instance.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("cannot request '%s': %w", u, err)
+	}
+	defer func() {
+		if cErr := resp.Body.Close(); cErr != nil && err == nil {
+			err = cErr
+		}
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return resp, fmt.Errorf("unexpected status while requesting '%s': %d - %s", u, resp.StatusCode, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(target); err != nil {
+		return resp, fmt.Errorf("cannot decode response of '%s': %w", u, err)
+	}
+	return resp, nil
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/echocat/slf4g"
+)
+
+var (
+	retryMaximumAttempts = flag.Int("retry-maximumAttempts", 6, "Maximum number of attempts of one API request before it fails.")
+	retryMaximumWait     = flag.Duration("retry-maximumWait", 10*time.Minute, "Maximum time to wait in total for retries of one API request, including waiting for a rate limit reset.")
+	retryInitialBackoff  = flag.Duration("retry-initialBackoff", time.Second, "Backoff before the first retry if the server does not tell how long to wait. It doubles with every further retry.")
+)
+
+// retryTransport retries requests which failed because of a transient error
+// or a rate limit. It understands the rate limit headers of GitHub
+// (X-RateLimit-*), GitLab (RateLimit-*) and the standard Retry-After header.
+type retryTransport struct {
+	delegate        http.RoundTripper
+	maximumAttempts int
+	maximumWait     time.Duration
+	initialBackoff  time.Duration
+	maximumBackoff  time.Duration
+
+	now    func() time.Time
+	sleep  func(ctx context.Context, d time.Duration) error
+	jitter func(d time.Duration) time.Duration
+}
+
+func newRetryTransport(delegate http.RoundTripper) *retryTransport {
+	return &retryTransport{
+		delegate:        delegate,
+		maximumAttempts: *retryMaximumAttempts,
+		maximumWait:     *retryMaximumWait,
+		initialBackoff:  *retryInitialBackoff,
+		maximumBackoff:  time.Minute,
+		now:             time.Now,
+		sleep:           sleepWithContext,
+		jitter: func(d time.Duration) time.Duration {
+			// Somewhere between the half and the full duration.
+			return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+		},
+	}
+}
+
+func (instance *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var waited time.Duration
+	for attempt := 1; ; attempt++ {
+		attemptReq, err := instance.requestForAttempt(req, attempt)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := instance.delegate.RoundTrip(attemptReq)
+		if err != nil && req.Context().Err() != nil {
+			return nil, err
+		}
+		delay, reason, retryable := instance.evaluate(resp, err, attempt)
+		if !retryable {
+			if err == nil {
+				instance.awaitExhaustedRateLimit(req, resp, waited)
+			}
+			return resp, err
+		}
+		if attempt >= instance.maximumAttempts || (req.Body != nil && req.GetBody == nil) {
+			return resp, err
+		}
+		if waited+delay > instance.maximumWait {
+			log.With("url", req.URL.Redacted()).
+				With("reason", reason).
+				With("waited", waited).
+				With("delay", delay).
+				Warn("Request failed and waiting for another retry would exceed the maximum wait time; giving up.")
+			return resp, err
+		}
+
+		if resp != nil {
+			// The response is dropped; drain it to allow reuse of the connection.
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+		}
+		log.With("url", req.URL.Redacted()).
+			With("reason", reason).
+			With("attempt", attempt).
+			With("delay", delay).
+			Warn("Request failed; retrying.")
+		if err := instance.sleep(req.Context(), delay); err != nil {
+			return nil, err
+		}
+		waited += delay
+	}
+}
+
+func (instance *retryTransport) requestForAttempt(req *http.Request, attempt int) (*http.Request, error) {
+	if attempt == 1 || req.Body == nil || req.GetBody == nil {
+		return req, nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, fmt.Errorf("cannot recreate body of request to '%s' for retry: %w", req.URL.Redacted(), err)
+	}
+	result := req.Clone(req.Context())
+	result.Body = body
+	return result, nil
+}
+
+// evaluate decides if the given result of an attempt should be retried and
+// how long to wait before.
+func (instance *retryTransport) evaluate(resp *http.Response, err error, attempt int) (delay time.Duration, reason string, retryable bool) {
+	if err != nil {
+		if isPermanentTransportError(err) {
+			return 0, "", false
+		}
+		return instance.backoff(attempt), err.Error(), true
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		reason = "too many requests"
+	case resp.StatusCode == http.StatusForbidden && instance.isRateLimited(resp):
+		reason = "rate limited"
+	case resp.StatusCode == http.StatusInternalServerError,
+		resp.StatusCode == http.StatusBadGateway,
+		resp.StatusCode == http.StatusServiceUnavailable,
+		resp.StatusCode == http.StatusGatewayTimeout:
+		reason = resp.Status
+	default:
+		return 0, "", false
+	}
+
+	if d, ok := instance.retryAfter(resp); ok {
+		return d, reason, true
+	}
+	// The reset is sent with every response; it only matters if the rate
+	// limit is exhausted.
+	if remaining, ok := instance.rateLimitRemaining(resp); ok && remaining == 0 {
+		if d, ok := instance.untilReset(resp); ok {
+			return d, reason, true
+		}
+	}
+	return instance.backoff(attempt), reason, true
+}
+
+// awaitExhaustedRateLimit waits until the rate limit is reset if the given
+// response used the last remaining request. Otherwise clients like go-github
+// would refuse the next request on their own without ever reaching this
+// transport.
+func (instance *retryTransport) awaitExhaustedRateLimit(req *http.Request, resp *http.Response, waited time.Duration) {
+	if remaining, ok := instance.rateLimitRemaining(resp); !ok || remaining > 0 {
+		return
+	}
+	delay, ok := instance.untilReset(resp)
+	if !ok || waited+delay > instance.maximumWait {
+		return
+	}
+	log.With("url", req.URL.Redacted()).
+		With("delay", delay).
+		Warn("Rate limit exhausted; waiting for its reset.")
+	_ = instance.sleep(req.Context(), delay)
+}
+
+func (instance *retryTransport) isRateLimited(resp *http.Response) bool {
+	if remaining, ok := instance.rateLimitRemaining(resp); ok && remaining == 0 {
+		return true
+	}
+	// GitHub's secondary rate limits do not touch the remaining requests but
+	// come with a Retry-After header.
+	return resp.Header.Get("Retry-After") != ""
+}
+
+func (instance *retryTransport) rateLimitRemaining(resp *http.Response) (int, bool) {
+	for _, header := range []string{"X-RateLimit-Remaining", "RateLimit-Remaining"} {
+		if v := resp.Header.Get(header); v != "" {
+			if remaining, err := strconv.Atoi(strings.TrimSpace(v)); err == nil {
+				return remaining, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// retryAfter parses the Retry-After header which is either in seconds or a
+// HTTP date.
+func (instance *retryTransport) retryAfter(resp *http.Response) (time.Duration, bool) {
+	v := strings.TrimSpace(resp.Header.Get("Retry-After"))
+	if v == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(v); err == nil {
+		return clampDuration(time.Duration(seconds) * time.Second), true
+	}
+	if at, err := http.ParseTime(v); err == nil {
+		return clampDuration(at.Sub(instance.now())), true
+	}
+	return 0, false
+}
+
+// untilReset parses X-RateLimit-Reset (GitHub) or RateLimit-Reset (GitLab)
+// which both contain the reset as unix timestamp.
+func (instance *retryTransport) untilReset(resp *http.Response) (time.Duration, bool) {
+	for _, header := range []string{"X-RateLimit-Reset", "RateLimit-Reset"} {
+		if v := resp.Header.Get(header); v != "" {
+			if epoch, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64); err == nil {
+				// One additional second because the reset is rounded down.
+				return clampDuration(time.Unix(epoch, 0).Sub(instance.now()) + time.Second), true
+			}
+		}
+	}
+	return 0, false
+}
+
+func (instance *retryTransport) backoff(attempt int) time.Duration {
+	result := instance.initialBackoff
+	for i := 1; i < attempt && result < instance.maximumBackoff; i++ {
+		result *= 2
+	}
+	if result > instance.maximumBackoff {
+		result = instance.maximumBackoff
+	}
+	return instance.jitter(result)
+}
+
+// isPermanentTransportError returns true for errors which will not go away
+// by retrying, like untrusted certificates.
+func isPermanentTransportError(err error) bool {
+	var certificateVerificationError *tls.CertificateVerificationError
+	var unknownAuthorityError x509.UnknownAuthorityError
+	var hostnameError x509.HostnameError
+	var certificateInvalidError x509.CertificateInvalidError
+	return errors.As(err, &certificateVerificationError) ||
+		errors.As(err, &unknownAuthorityError) ||
+		errors.As(err, &hostnameError) ||
+		errors.As(err, &certificateInvalidError)
+}
+
+func clampDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+func sleepWithContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type retryTransportSuite struct{}
+
+var _ = Suite(&retryTransportSuite{})
+
+// retryTestServer answers each request with the next response of the given
+// sequence; the last one is repeated.
+type retryTestServer struct {
+	*httptest.Server
+	mutex    sync.Mutex
+	bodies   []string
+	requests int
+}
+
+type retryTestResponse struct {
+	status  int
+	headers map[string]string
+}
+
+func newRetryTestServer(sequence ...retryTestResponse) *retryTestServer {
+	result := &retryTestServer{}
+	result.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		result.mutex.Lock()
+		current := sequence[min(result.requests, len(sequence)-1)]
+		result.requests++
+		buf := new(strings.Builder)
+		if r.Body != nil {
+			b := make([]byte, 64)
+			n, _ := r.Body.Read(b)
+			buf.Write(b[:n])
+		}
+		result.bodies = append(result.bodies, buf.String())
+		result.mutex.Unlock()
+
+		for key, value := range current.headers {
+			w.Header().Set(key, value)
+		}
+		w.WriteHeader(current.status)
+		_, _ = w.Write([]byte(strconv.Itoa(current.status)))
+	}))
+	return result
+}
+
+// newRetryTestTransport records all sleeps instead of sleeping.
+func newRetryTestTransport(now time.Time) (*retryTransport, *[]time.Duration) {
+	var sleeps []time.Duration
+	result := newRetryTransport(http.DefaultTransport)
+	result.maximumAttempts = 5
+	result.maximumWait = time.Hour
+	result.initialBackoff = time.Second
+	result.now = func() time.Time { return now }
+	result.jitter = func(d time.Duration) time.Duration { return d }
+	result.sleep = func(_ context.Context, d time.Duration) error {
+		sleeps = append(sleeps, d)
+		return nil
+	}
+	return result, &sleeps
+}
+
+func (s *retryTransportSuite) TestRetriesServerErrorsWithBackoff(c *C) {
+	server := newRetryTestServer(
+		retryTestResponse{status: http.StatusBadGateway},
+		retryTestResponse{status: http.StatusServiceUnavailable},
+		retryTestResponse{status: http.StatusOK},
+	)
+	defer server.Close()
+	instance, sleeps := newRetryTestTransport(time.Now())
+
+	resp, err := (&http.Client{Transport: instance}).Get(server.URL)
+
+	c.Assert(err, IsNil)
+	c.Assert(resp.StatusCode, Equals, http.StatusOK)
+	c.Assert(server.requests, Equals, 3)
+	c.Assert(*sleeps, DeepEquals, []time.Duration{time.Second, 2 * time.Second})
+}
+
+func (s *retryTransportSuite) TestRetriesTooManyRequestsWithRetryAfter(c *C) {
+	server := newRetryTestServer(
+		retryTestResponse{status: http.StatusTooManyRequests, headers: map[string]string{"Retry-After": "7"}},
+		retryTestResponse{status: http.StatusOK},
+	)
+	defer server.Close()
+	instance, sleeps := newRetryTestTransport(time.Now())
+
+	resp, err := (&http.Client{Transport: instance}).Post(server.URL, "text/plain", strings.NewReader("query"))
+
+	c.Assert(err, IsNil)
+	c.Assert(resp.StatusCode, Equals, http.StatusOK)
+	c.Assert(*sleeps, DeepEquals, []time.Duration{7 * time.Second})
+	c.Assert(server.bodies, DeepEquals, []string{"query", "query"})
+}
+
+func (s *retryTransportSuite) TestRetriesGithubRateLimitUntilReset(c *C) {
+	now := time.Unix(1700000000, 0)
+	server := newRetryTestServer(
+		retryTestResponse{status: http.StatusForbidden, headers: map[string]string{
+			"X-RateLimit-Remaining": "0",
+			"X-RateLimit-Reset":     strconv.FormatInt(now.Add(30*time.Second).Unix(), 10),
+		}},
+		retryTestResponse{status: http.StatusOK},
+	)
+	defer server.Close()
+	instance, sleeps := newRetryTestTransport(now)
+
+	resp, err := (&http.Client{Transport: instance}).Get(server.URL)
+
+	c.Assert(err, IsNil)
+	c.Assert(resp.StatusCode, Equals, http.StatusOK)
+	c.Assert(*sleeps, DeepEquals, []time.Duration{31 * time.Second})
+}
+
+func (s *retryTransportSuite) TestWaitsForResetIfGitlabRateLimitIsExhausted(c *C) {
+	now := time.Unix(1700000000, 0)
+	server := newRetryTestServer(
+		retryTestResponse{status: http.StatusOK, headers: map[string]string{
+			"RateLimit-Remaining": "0",
+			"RateLimit-Reset":     strconv.FormatInt(now.Add(9*time.Second).Unix(), 10),
+		}},
+	)
+	defer server.Close()
+	instance, sleeps := newRetryTestTransport(now)
+
+	resp, err := (&http.Client{Transport: instance}).Get(server.URL)
+
+	c.Assert(err, IsNil)
+	c.Assert(resp.StatusCode, Equals, http.StatusOK)
+	c.Assert(server.requests, Equals, 1)
+	c.Assert(*sleeps, DeepEquals, []time.Duration{10 * time.Second})
+}
+
+func (s *retryTransportSuite) TestDoesNotRetryOtherErrors(c *C) {
+	server := newRetryTestServer(
+		retryTestResponse{status: http.StatusForbidden, headers: map[string]string{"X-RateLimit-Remaining": "10"}},
+	)
+	defer server.Close()
+	instance, sleeps := newRetryTestTransport(time.Now())
+
+	resp, err := (&http.Client{Transport: instance}).Get(server.URL)
+
+	c.Assert(err, IsNil)
+	c.Assert(resp.StatusCode, Equals, http.StatusForbidden)
+	c.Assert(server.requests, Equals, 1)
+	c.Assert(*sleeps, HasLen, 0)
+}
+
+func (s *retryTransportSuite) TestGivesUpAfterMaximumAttemptsAndWait(c *C) {
+	server := newRetryTestServer(retryTestResponse{status: http.StatusInternalServerError})
+	defer server.Close()
+	instance, sleeps := newRetryTestTransport(time.Now())
+
+	resp, err := (&http.Client{Transport: instance}).Get(server.URL)
+
+	c.Assert(err, IsNil)
+	c.Assert(resp.StatusCode, Equals, http.StatusInternalServerError)
+	c.Assert(server.requests, Equals, 5)
+	c.Assert(*sleeps, HasLen, 4)
+
+	server.requests = 0
+	*sleeps = nil
+	instance.maximumWait = 2 * time.Second
+	resp, err = (&http.Client{Transport: instance}).Get(server.URL)
+
+	c.Assert(err, IsNil)
+	c.Assert(resp.StatusCode, Equals, http.StatusInternalServerError)
+	c.Assert(server.requests, Equals, 2)
+	c.Assert(*sleeps, DeepEquals, []time.Duration{time.Second})
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	log "github.com/echocat/slf4g"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	explain = flag.Bool("explain", false, "Log for every dropped project and member which rule dropped it.")
+)
+
+// rules decide which of the retrieved projects and members are kept.
+type rules struct {
+	// Projects are evaluated in order; the first matching rule decides. If
+	// no rule matches the project is included.
+	Projects []projectRule `yaml:"projects"`
+	Members  memberRules   `yaml:"members"`
+}
+
+type ruleAction string
+
+const (
+	ruleActionInclude = ruleAction("include")
+	ruleActionExclude = ruleAction("exclude")
+)
+
+// projectRule matches a project if all of its configured criteria match.
+type projectRule struct {
+	// Description of the rule; only used for logging.
+	Description string     `yaml:"description"`
+	Action      ruleAction `yaml:"action"`
+
+	Origin string `yaml:"origin"`
+	// NamePattern is a regular expression which has to match the name or
+	// the fullname of the project.
+	NamePattern *rulePattern `yaml:"name"`
+	Language    string       `yaml:"language"`
+	// Topics matches if the project has at least one of these topics.
+	Topics   []string `yaml:"topics"`
+	Fork     *bool    `yaml:"fork"`
+	Archived *bool    `yaml:"archived"`
+	// InactiveFor matches if the last activity of the project is older.
+	InactiveFor time.Duration `yaml:"inactiveFor"`
+	// StarsBelow matches if the project has fewer stars. Together with the
+	// exclude action this is the minimum number of stars a project needs.
+	StarsBelow *uint32 `yaml:"starsBelow"`
+}
+
+// memberRules are matched against the name of each member. If Allow is not
+// empty only members matching one of its patterns are kept; members matching
+// one of the Deny patterns are always dropped.
+type memberRules struct {
+	Allow []*rulePattern `yaml:"allow"`
+	Deny  []*rulePattern `yaml:"deny"`
+}
+
+// rulePattern is a regular expression which can be read from YAML.
+type rulePattern struct {
+	*regexp.Regexp
+}
+
+func mustRulePattern(expr string) *rulePattern {
+	return &rulePattern{regexp.MustCompile(expr)}
+}
+
+func (instance *rulePattern) UnmarshalYAML(node *yaml.Node) error {
+	var expr string
+	if err := node.Decode(&expr); err != nil {
+		return err
+	}
+	compiled, err := regexp.Compile(expr)
+	if err != nil {
+		return fmt.Errorf("line %d: illegal pattern '%s': %w", node.Line, expr, err)
+	}
+	instance.Regexp = compiled
+	return nil
+}
+
+func defaultRules() rules {
+	return rules{
+		Projects: []projectRule{{
+			Description: "echocat.org",
+			Action:      ruleActionExclude,
+			Origin:      "github",
+			NamePattern: mustRulePattern(`^echocat\.org$`),
+		}, {
+			Description: "archived",
+			Action:      ruleActionExclude,
+			Archived:    pBool(true),
+		}},
+		Members: memberRules{
+			Deny: []*rulePattern{
+				mustRulePattern(`^echocat-bot$`),
+				mustRulePattern(`^echocat$`),
+			},
+		},
+	}
+}
+
+// withDefaults appends the project rules of defaultRules to the ones of this
+// rules, so these rules decide first but archived projects stay excluded
+// unless a rule includes them. The member rules of defaultRules are only used
+// if these rules have none.
+func (instance rules) withDefaults() rules {
+	defaults := defaultRules()
+	result := instance
+	result.Projects = append(append([]projectRule{}, instance.Projects...), defaults.Projects...)
+	if len(result.Members.Allow) == 0 && len(result.Members.Deny) == 0 {
+		result.Members = defaults.Members
+	}
+	return result
+}
+
+func (instance rules) validate() error {
+	for i, rule := range instance.Projects {
+		if rule.Action != ruleActionInclude && rule.Action != ruleActionExclude {
+			return fmt.Errorf("rules.projects[%d] has the illegal action '%s'", i, rule.Action)
+		}
+	}
+	return nil
+}
+
+func (instance rules) includesProject(candidate project, now time.Time) bool {
+	for i, rule := range instance.Projects {
+		if rule.matches(candidate, now) {
+			if rule.Action == ruleActionExclude {
+				instance.explain("project", candidate.Origin+":"+candidate.Fullname, rule.describe(i))
+				return false
+			}
+			return true
+		}
+	}
+	return true
+}
+
+func (instance rules) includesMember(candidate member) bool {
+	for i, pattern := range instance.Members.Deny {
+		if pattern.MatchString(candidate.Name) {
+			instance.explain("member", candidate.Name, fmt.Sprintf("members.deny[%d] (%s)", i, pattern))
+			return false
+		}
+	}
+	if len(instance.Members.Allow) == 0 {
+		return true
+	}
+	for _, pattern := range instance.Members.Allow {
+		if pattern.MatchString(candidate.Name) {
+			return true
+		}
+	}
+	instance.explain("member", candidate.Name, "members.allow (no pattern matches)")
+	return false
+}
+
+func (instance rules) explain(kind, reference, rule string) {
+	if *explain {
+		log.With(kind, reference).
+			With("rule", rule).
+			Info("Dropped by rule.")
+	}
+}
+
+func (instance projectRule) matches(candidate project, now time.Time) bool {
+	if instance.Origin != "" && instance.Origin != candidate.Origin {
+		return false
+	}
+	if instance.NamePattern != nil && !instance.NamePattern.MatchString(candidate.Name) && !instance.NamePattern.MatchString(candidate.Fullname) {
+		return false
+	}
+	if instance.Language != "" && (candidate.Language == nil || !strings.EqualFold(canonicalLanguage(instance.Language), *candidate.Language)) {
+		return false
+	}
+	if len(instance.Topics) > 0 && !candidate.hasAnyTopic(instance.Topics) {
+		return false
+	}
+	if instance.Fork != nil && *instance.Fork != candidate.Fork {
+		return false
+	}
+	if instance.Archived != nil && *instance.Archived != candidate.Archived {
+		return false
+	}
+	if instance.InactiveFor > 0 && (candidate.UpdatedAt == nil || now.Sub(*candidate.UpdatedAt) < instance.InactiveFor) {
+		return false
+	}
+	if instance.StarsBelow != nil && candidate.NumberOfStars != nil && *candidate.NumberOfStars >= *instance.StarsBelow {
+		return false
+	}
+	return true
+}
+
+func (instance projectRule) describe(index int) string {
+	if instance.Description != "" {
+		return fmt.Sprintf("projects[%d] (%s)", index, instance.Description)
+	}
+	return fmt.Sprintf("projects[%d]", index)
+}
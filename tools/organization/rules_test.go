@@ -0,0 +1,90 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type rulesSuite struct{}
+
+var _ = Suite(&rulesSuite{})
+
+func (s *rulesSuite) TestDefaultRules(c *C) {
+	instance := defaultRules()
+
+	actual := organization{
+		Projects: projects{
+			{Origin: "github", Name: "echocat.org", Fullname: "echocat/echocat.org"},
+			{Origin: "gitlab", Name: "echocat.org", Fullname: "echocat.org"},
+			{Origin: "github", Name: "old", Fullname: "echocat/old", Archived: true},
+		},
+		Members: members{
+			{Name: "echocat-bot"},
+			{Name: "echocat"},
+			{Name: "jdoe"},
+		},
+	}.clean(instance)
+
+	c.Assert(actual.Projects, HasLen, 1)
+	c.Assert(actual.Projects[0].Origin, Equals, "gitlab")
+	c.Assert(actual.Members, HasLen, 1)
+	c.Assert(actual.Members[0].Name, Equals, "jdoe")
+}
+
+func (s *rulesSuite) TestRulesFromConfiguration(c *C) {
+	file := filepath.Join(c.MkDir(), "config.yml")
+	c.Assert(os.WriteFile(file, []byte(`
+sources:
+  - type: github
+    organization: acme
+rules:
+  projects:
+    - action: include
+      topics: [keep]
+    - description: internal
+      action: exclude
+      name: "^internal-"
+    - action: exclude
+      language: java
+      fork: true
+    - action: exclude
+      inactiveFor: 8760h
+    - action: exclude
+      starsBelow: 2
+  members:
+    allow: ["^j"]
+    deny: ["-bot$"]
+`), 0644), IsNil)
+	config, err := loadConfigurationFrom(file)
+	c.Assert(err, IsNil)
+	instance := config.rules()
+	now := time.Now()
+	old := now.Add(-2 * 8760 * time.Hour)
+
+	c.Assert(instance.includesProject(project{Name: "internal-foo", Topics: []string{"Keep"}}, now), Equals, true)
+	c.Assert(instance.includesProject(project{Name: "internal-foo", NumberOfStars: pUint32(5)}, now), Equals, false)
+	c.Assert(instance.includesProject(project{Name: "foo", Language: pString("Java"), Fork: true, NumberOfStars: pUint32(5)}, now), Equals, false)
+	c.Assert(instance.includesProject(project{Name: "foo", Language: pString("Java"), NumberOfStars: pUint32(5)}, now), Equals, true)
+	c.Assert(instance.includesProject(project{Name: "foo", UpdatedAt: &old, NumberOfStars: pUint32(5)}, now), Equals, false)
+	c.Assert(instance.includesProject(project{Name: "foo", UpdatedAt: &now, NumberOfStars: pUint32(1)}, now), Equals, false)
+	c.Assert(instance.includesProject(project{Name: "foo", UpdatedAt: &now, NumberOfStars: pUint32(2)}, now), Equals, true)
+	// The default rules are evaluated after the configured ones.
+	c.Assert(instance.includesProject(project{Name: "foo", UpdatedAt: &now, NumberOfStars: pUint32(2), Archived: true}, now), Equals, false)
+	c.Assert(instance.includesProject(project{Name: "foo", Topics: []string{"keep"}, Archived: true}, now), Equals, true)
+	c.Assert(instance.includesProject(project{Origin: "github", Name: "echocat.org", Topics: []string{"keep"}}, now), Equals, true)
+
+	c.Assert(instance.includesMember(member{Name: "jdoe"}), Equals, true)
+	c.Assert(instance.includesMember(member{Name: "jenkins-bot"}), Equals, false)
+	c.Assert(instance.includesMember(member{Name: "alice"}), Equals, false)
+}
+
+func (s *rulesSuite) TestRejectsIllegalAction(c *C) {
+	file := filepath.Join(c.MkDir(), "config.yml")
+	c.Assert(os.WriteFile(file, []byte(`{"sources": [{"type": "github", "organization": "acme"}], "rules": {"projects": [{"action": "drop"}]}}`), 0644), IsNil)
+
+	_, err := loadConfigurationFrom(file)
+	c.Assert(err, ErrorMatches, ".*illegal action 'drop'.*")
+}
@@ -21,10 +21,10 @@ func newSha256reader(delegate io.Reader) *sha256reader {
 
 func (instance *sha256reader) Read(p []byte) (n int, err error) {
 	n, err = instance.delegate.Read(p)
-	if err != nil || n <= 0 {
-		return
+	// Readers are allowed to return the last bytes together with io.EOF.
+	if n > 0 {
+		_, _ = instance.hash.Write(p[:n])
 	}
-	_, err = instance.hash.Write(p[:n])
 	return
 }
 
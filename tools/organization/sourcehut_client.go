@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	sourcehutMaximumNumberOfEntries = flag.Int("sourcehut-maximumNumberOfEntries", -1, "")
+	sourcehutAccessToken            = flag.String("sourcehutAccessToken", "", "SourceHut OAuth2 accessToken to access the GraphQL APIs.")
+)
+
+const (
+	sourcehutRepositoriesQuery = `query repositories($username: String!, $cursor: Cursor) {
+	user(username: $username) {
+		repositories(cursor: $cursor) {
+			cursor
+			results { name description visibility created updated HEAD { name } }
+		}
+	}
+}`
+	sourcehutTicketsQuery = `query tickets($username: String!, $tracker: String!, $cursor: Cursor) {
+	user(username: $username) {
+		tracker(name: $tracker) {
+			tickets(cursor: $cursor) {
+				cursor
+				results { status }
+			}
+		}
+	}
+}`
+)
+
+func init() {
+	clientFactories["sourcehut"] = func(_ *assetClient, transport http.RoundTripper, source sourceConfiguration) (client, error) {
+		if source.BaseUrl == "" {
+			source.BaseUrl = "https://git.sr.ht"
+		}
+		if source.TrackerBaseUrl == "" {
+			u, err := url.Parse(source.BaseUrl)
+			if err != nil {
+				return nil, fmt.Errorf("illegal baseUrl '%s': %w", source.BaseUrl, err)
+			}
+			u.Host = "todo." + strings.TrimPrefix(u.Host, "git.")
+			source.TrackerBaseUrl = u.String()
+		}
+		return newSourcehutClient(transport, source), nil
+	}
+}
+
+// sourcehutClient retrieves the public repositories of a git.sr.ht user
+// together with the open tickets of the todo.sr.ht tracker with the same
+// name. SourceHut has no organizations, so the organization is a username.
+type sourcehutClient struct {
+	username               string
+	baseUrl                string
+	trackerBaseUrl         string
+	accessToken            string
+	maximumNumberOfEntries int
+	transport              http.RoundTripper
+}
+
+type sourcehutClientRetrieveTask struct {
+	*sourcehutClient
+
+	git  *graphqlClient
+	todo *graphqlClient
+	ctx  context.Context
+}
+
+type sourcehutRepository struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Visibility  string    `json:"visibility"`
+	Created     time.Time `json:"created"`
+	Updated     time.Time `json:"updated"`
+	Head        *struct {
+		Name string `json:"name"`
+	} `json:"HEAD"`
+}
+
+func newSourcehutClient(transport http.RoundTripper, source sourceConfiguration) *sourcehutClient {
+	return &sourcehutClient{
+		username:               strings.TrimPrefix(source.Organization, "~"),
+		baseUrl:                strings.TrimSuffix(source.BaseUrl, "/"),
+		trackerBaseUrl:         strings.TrimSuffix(source.TrackerBaseUrl, "/"),
+		accessToken:            source.accessToken(*sourcehutAccessToken),
+		maximumNumberOfEntries: source.maximumNumberOfEntries(*sourcehutMaximumNumberOfEntries),
+		transport:              transport,
+	}
+}
+
+func (instance *sourcehutClient) retrieveOrganization() (organization, error) {
+	ctx := context.Background()
+
+	headers := http.Header{}
+	if len(instance.accessToken) > 0 {
+		headers.Set("Authorization", "Bearer "+instance.accessToken)
+	}
+
+	task := sourcehutClientRetrieveTask{
+		sourcehutClient: instance,
+		git:             newGraphqlClient(instance.baseUrl+"/query", headers, instance.transport),
+		todo:            newGraphqlClient(instance.trackerBaseUrl+"/query", headers, instance.transport),
+		ctx:             ctx,
+	}
+
+	return task.execute()
+}
+
+func (instance *sourcehutClientRetrieveTask) execute() (organization, error) {
+	if projects, err := instance.retrieveProjects(); err != nil {
+		return organization{}, err
+	} else {
+		result := organization{
+			Projects: projects,
+		}
+		result.align()
+		return result, nil
+	}
+}
+
+func (instance *sourcehutClientRetrieveTask) retrieveProjects() ([]project, error) {
+	var result []project
+	var cursor *string
+	for i := 1; instance.maximumNumberOfEntries < 0 || i < instance.maximumNumberOfEntries; {
+		var data struct {
+			User *struct {
+				Repositories struct {
+					Cursor  *string               `json:"cursor"`
+					Results []sourcehutRepository `json:"results"`
+				} `json:"repositories"`
+			} `json:"user"`
+		}
+		if err := instance.git.query(instance.ctx, sourcehutRepositoriesQuery, map[string]interface{}{
+			"username": instance.username,
+			"cursor":   cursor,
+		}, &data); err != nil {
+			return result, fmt.Errorf("cannot search for repositories: %w", err)
+		}
+		if data.User == nil {
+			return result, fmt.Errorf("unknown SourceHut user '~%s'", instance.username)
+		}
+		for _, repo := range data.User.Repositories.Results {
+			if instance.maximumNumberOfEntries > 0 && i > instance.maximumNumberOfEntries {
+				break
+			}
+			if repo.Visibility == "PUBLIC" {
+				if project, err := instance.repoToProject(repo); err != nil {
+					return nil, fmt.Errorf("cannot get details of project '%s': %w", repo.Name, err)
+				} else {
+					result = append(result, project)
+				}
+				i++
+			}
+		}
+
+		if data.User.Repositories.Cursor == nil {
+			break
+		}
+		cursor = data.User.Repositories.Cursor
+	}
+	return result, nil
+}
+
+// openTicketsOf returns the number of not resolved tickets of the tracker
+// with the given name or nil if there is no such tracker.
+func (instance *sourcehutClientRetrieveTask) openTicketsOf(tracker string) (*uint32, error) {
+	var result uint32
+	var cursor *string
+	for {
+		var data struct {
+			User *struct {
+				Tracker *struct {
+					Tickets struct {
+						Cursor  *string `json:"cursor"`
+						Results []struct {
+							Status string `json:"status"`
+						} `json:"results"`
+					} `json:"tickets"`
+				} `json:"tracker"`
+			} `json:"user"`
+		}
+		if err := instance.todo.query(instance.ctx, sourcehutTicketsQuery, map[string]interface{}{
+			"username": instance.username,
+			"tracker":  tracker,
+			"cursor":   cursor,
+		}, &data); err != nil {
+			return nil, fmt.Errorf("cannot retrieve tickets of tracker '%s': %w", tracker, err)
+		}
+		if data.User == nil || data.User.Tracker == nil {
+			return nil, nil
+		}
+		for _, ticket := range data.User.Tracker.Tickets.Results {
+			if ticket.Status != "RESOLVED" {
+				result++
+			}
+		}
+
+		if data.User.Tracker.Tickets.Cursor == nil {
+			return &result, nil
+		}
+		cursor = data.User.Tracker.Tickets.Cursor
+	}
+}
+
+func (instance *sourcehutClientRetrieveTask) repoToProject(repo sourcehutRepository) (project, error) {
+	openTickets, err := instance.openTicketsOf(repo.Name)
+	if err != nil {
+		return project{}, err
+	}
+
+	name := repo.Name
+	fullname := "~" + instance.username + "/" + name
+	profile := instance.baseUrl + "/" + fullname
+	issuesUrl := ""
+	if openTickets != nil {
+		issuesUrl = instance.trackerBaseUrl + "/" + fullname
+	}
+	sshCloneUrl := ""
+	if u, err := url.Parse(instance.baseUrl); err == nil {
+		sshCloneUrl = "git@" + u.Host + ":" + fullname
+	}
+
+	defaultBranch := ""
+	if repo.Head != nil {
+		defaultBranch = strings.TrimPrefix(repo.Head.Name, "refs/heads/")
+	}
+
+	return project{
+		Type:               "repository:git:sourcehut",
+		Origin:             "sourcehut",
+		Fullname:           fullname,
+		Name:               name,
+		Description:        pNonEmptyString(repo.Description),
+		DefaultBranch:      pNonEmptyString(defaultBranch),
+		HomepageUrl:        &profile,
+		ProfileUrl:         profile,
+		HttpCloneUrl:       &profile,
+		SshCloneUrl:        pNonEmptyString(sshCloneUrl),
+		IssuesUrl:          pNonEmptyString(issuesUrl),
+		NumberOfOpenIssues: openTickets,
+		CreatedAt:          pTime(repo.Created),
+		UpdatedAt:          pTime(repo.Updated),
+	}, nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "gopkg.in/check.v1"
+)
+
+type sourcehutClientSuite struct {
+	git  *httptest.Server
+	todo *httptest.Server
+}
+
+var _ = Suite(&sourcehutClientSuite{})
+
+func (s *sourcehutClientSuite) SetUpTest(c *C) {
+	// Stand-ins for the GraphQL endpoints which dispatch by the query name.
+	handler := func(responses func(req graphqlRequest) string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			c.Check(r.Method, Equals, http.MethodPost)
+			c.Check(r.URL.Path, Equals, "/query")
+			c.Check(r.Header.Get("Authorization"), Equals, "Bearer secret")
+			var req graphqlRequest
+			c.Assert(json.NewDecoder(r.Body).Decode(&req), IsNil)
+			c.Check(req.Variables["username"], Equals, "jdoe")
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(responses(req)))
+		}
+	}
+
+	s.git = httptest.NewServer(handler(func(req graphqlRequest) string {
+		c.Assert(strings.HasPrefix(req.Query, "query repositories("), Equals, true)
+		if req.Variables["cursor"] == nil {
+			return `{"data": {"user": {"repositories": {"cursor": "next", "results": [
+				{"name": "foo", "description": "Foo!", "visibility": "PUBLIC", "created": "2020-01-02T03:04:05Z", "updated": "2021-01-02T03:04:05Z", "HEAD": {"name": "refs/heads/main"}},
+				{"name": "secret", "visibility": "PRIVATE", "created": "2020-01-02T03:04:05Z", "updated": "2021-01-02T03:04:05Z"}
+			]}}}}`
+		}
+		return `{"data": {"user": {"repositories": {"cursor": null, "results": [
+			{"name": "bar", "visibility": "PUBLIC", "created": "2020-01-02T03:04:05Z", "updated": "2022-01-02T03:04:05Z"}
+		]}}}}`
+	}))
+	s.todo = httptest.NewServer(handler(func(req graphqlRequest) string {
+		c.Assert(strings.HasPrefix(req.Query, "query tickets("), Equals, true)
+		if req.Variables["tracker"] != "foo" {
+			return `{"data": {"user": {"tracker": null}}}`
+		}
+		if req.Variables["cursor"] == nil {
+			return `{"data": {"user": {"tracker": {"tickets": {"cursor": "next", "results": [
+				{"status": "REPORTED"}, {"status": "RESOLVED"}
+			]}}}}}`
+		}
+		return `{"data": {"user": {"tracker": {"tickets": {"cursor": null, "results": [
+			{"status": "CONFIRMED"}, {"status": "IN_PROGRESS"}
+		]}}}}}`
+	}))
+}
+
+func (s *sourcehutClientSuite) TearDownTest(_ *C) {
+	s.git.Close()
+	s.todo.Close()
+}
+
+func (s *sourcehutClientSuite) TestRetrieveOrganization(c *C) {
+	*sourcehutAccessToken = "secret"
+	defer func() { *sourcehutAccessToken = "" }()
+	instance := newSourcehutClient(http.DefaultTransport, sourceConfiguration{
+		Organization:   "~jdoe",
+		BaseUrl:        s.git.URL,
+		TrackerBaseUrl: s.todo.URL,
+	})
+
+	actual, err := instance.retrieveOrganization()
+	c.Assert(err, IsNil)
+
+	c.Assert(actual.Members, HasLen, 0)
+	c.Assert(actual.Projects, HasLen, 2)
+
+	bar := actual.Projects[0]
+	c.Assert(bar.Name, Equals, "bar")
+	c.Assert(bar.IssuesUrl, IsNil)
+	c.Assert(bar.NumberOfOpenIssues, IsNil)
+
+	foo := actual.Projects[1]
+	c.Assert(foo.Type, Equals, "repository:git:sourcehut")
+	c.Assert(foo.Fullname, Equals, "~jdoe/foo")
+	c.Assert(*foo.DefaultBranch, Equals, "main")
+	c.Assert(*foo.HttpCloneUrl, Equals, s.git.URL+"/~jdoe/foo")
+	c.Assert(*foo.SshCloneUrl, Equals, "git@"+strings.TrimPrefix(s.git.URL, "http://")+":~jdoe/foo")
+	c.Assert(*foo.IssuesUrl, Equals, s.todo.URL+"/~jdoe/foo")
+	c.Assert(*foo.NumberOfOpenIssues, Equals, uint32(3))
+}
+
+func (s *sourcehutClientSuite) TestTrackerBaseUrlIsDerived(c *C) {
+	actual, err := clientFactories["sourcehut"](nil, http.DefaultTransport, sourceConfiguration{Organization: "jdoe"})
+	c.Assert(err, IsNil)
+	c.Assert(actual.(*sourcehutClient).baseUrl, Equals, "https://git.sr.ht")
+	c.Assert(actual.(*sourcehutClient).trackerBaseUrl, Equals, "https://todo.sr.ht")
+}
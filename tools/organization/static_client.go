@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+func init() {
+	clientFactories["static"] = func(assetClient *assetClient, _ http.RoundTripper, source sourceConfiguration) (client, error) {
+		if source.File == "" {
+			return nil, fmt.Errorf("source of type '%s' requires a file", source.Type)
+		}
+		return newStaticClient(assetClient, source), nil
+	}
+}
+
+// staticClient reads hand maintained projects and members from a YAML (or
+// JSON) file. The entries use the same keys as the organization.json output
+// plus "image" which is either a URL or a path to a local image file
+// (relative to the file itself).
+type staticClient struct {
+	file        string
+	assetClient *assetClient
+}
+
+type staticFile struct {
+	Projects []staticProject `json:"projects"`
+	Members  []staticMember  `json:"members"`
+}
+
+type staticProject struct {
+	project
+	Image string `json:"image"`
+}
+
+type staticMember struct {
+	member
+	Image string `json:"image"`
+}
+
+func newStaticClient(assetClient *assetClient, source sourceConfiguration) *staticClient {
+	return &staticClient{
+		file:        source.File,
+		assetClient: assetClient,
+	}
+}
+
+func (instance *staticClient) retrieveOrganization() (organization, error) {
+	content, err := instance.read()
+	if err != nil {
+		return organization{}, err
+	}
+
+	result := organization{}
+	for i, in := range content.Projects {
+		if p, err := instance.toProject(in); err != nil {
+			return organization{}, fmt.Errorf("cannot handle projects[%d] of '%s': %w", i, instance.file, err)
+		} else {
+			result.Projects = append(result.Projects, p)
+		}
+	}
+	for i, in := range content.Members {
+		if m, err := instance.toMember(in); err != nil {
+			return organization{}, fmt.Errorf("cannot handle members[%d] of '%s': %w", i, instance.file, err)
+		} else {
+			result.Members = append(result.Members, m)
+		}
+	}
+
+	result.align()
+	return result, nil
+}
+
+func (instance *staticClient) read() (result staticFile, err error) {
+	b, err := os.ReadFile(instance.file)
+	if err != nil {
+		return staticFile{}, fmt.Errorf("cannot read '%s': %w", instance.file, err)
+	}
+
+	// The entries are decoded using the JSON names of project and member to
+	// keep the file in sync with organization.json. That's why the YAML is
+	// first converted into JSON.
+	var raw interface{}
+	if err := yaml.Unmarshal(b, &raw); err != nil {
+		return staticFile{}, fmt.Errorf("cannot parse '%s': %w", instance.file, err)
+	}
+	asJson, err := json.Marshal(raw)
+	if err != nil {
+		return staticFile{}, fmt.Errorf("cannot convert '%s': %w", instance.file, err)
+	}
+	if err := json.Unmarshal(asJson, &result); err != nil {
+		return staticFile{}, fmt.Errorf("cannot parse '%s': %w", instance.file, err)
+	}
+	return result, nil
+}
+
+func (instance *staticClient) toProject(in staticProject) (project, error) {
+	result := in.project
+	if result.Name == "" {
+		return project{}, fmt.Errorf("no name provided")
+	}
+	if result.Fullname == "" {
+		result.Fullname = result.Name
+	}
+	if result.Type == "" {
+		result.Type = "repository:static"
+	}
+	if result.Origin == "" {
+		result.Origin = "static"
+	}
+	if in.Image != "" {
+		if imageAsset, err := instance.retrieveImage(projectAssetOwner(result.Origin, result.Fullname), in.Image); err != nil {
+			return project{}, err
+		} else {
+			result.ImageAsset = &imageAsset
+		}
+	}
+	return result, nil
+}
+
+func (instance *staticClient) toMember(in staticMember) (member, error) {
+	result := in.member
+	if result.Name == "" {
+		return member{}, fmt.Errorf("no name provided")
+	}
+	if result.Fullname == "" {
+		result.Fullname = result.Name
+	}
+	if result.Type == "" {
+		result.Type = "user:static"
+	}
+	if in.Image != "" {
+		if imageAsset, err := instance.retrieveImage(memberAssetOwner(result.Name), in.Image); err != nil {
+			return member{}, err
+		} else {
+			result.ImageAsset = imageAsset
+		}
+	}
+	return result, nil
+}
+
+func (instance *staticClient) retrieveImage(owner assetOwner, image string) (string, error) {
+	return instance.assetClient.retrieveFromLocation(owner, image, filepath.Dir(instance.file))
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+)
+
+type staticClientSuite struct{}
+
+var _ = Suite(&staticClientSuite{})
+
+func (s *staticClientSuite) TestRetrieveOrganization(c *C) {
+	*assetsFolder = c.MkDir()
+	instance := newStaticClient(newAssetClient(http.DefaultTransport), sourceConfiguration{File: "testdata/static/organization.yml"})
+
+	actual, err := instance.retrieveOrganization()
+	c.Assert(err, IsNil)
+
+	c.Assert(actual.Projects, HasLen, 1)
+	legacy := actual.Projects[0]
+	c.Assert(legacy.Type, Equals, "repository:static")
+	c.Assert(legacy.Origin, Equals, "static")
+	c.Assert(legacy.Fullname, Equals, "echocat/legacy")
+	c.Assert(*legacy.Language, Equals, "Java")
+	c.Assert(*legacy.NumberOfStars, Equals, uint32(5))
+	c.Assert(legacy.UpdatedAt.Year(), Equals, 2019)
+	c.Assert(actual.Statistics.NumberOfStars, Equals, uint32(5))
+
+	c.Assert(actual.Members, HasLen, 1)
+	alumni := actual.Members[0]
+	c.Assert(alumni.Type, Equals, "user:static")
+	c.Assert(alumni.Fullname, Equals, "Alumni Member")
+	c.Assert(*alumni.Location, Equals, "Berlin")
+	c.Assert(filepath.Ext(alumni.ImageAsset), Equals, ".gif")
+	_, err = os.Stat(filepath.Join(*assetsFolder, alumni.ImageAsset))
+	c.Assert(err, IsNil)
+}
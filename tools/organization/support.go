@@ -16,6 +16,10 @@ func pNonEmptyString(input string) *string {
 	return &input
 }
 
+func pBool(input bool) *bool {
+	return &input
+}
+
 func pUint32(input uint32) *uint32 {
 	return &input
 }
@@ -27,3 +31,10 @@ func pTime(input time.Time) *time.Time {
 func pGitlabVisibilityValue(input gitlab.VisibilityValue) *gitlab.VisibilityValue {
 	return &input
 }
+
+func stringOf(input *string) string {
+	if input == nil {
+		return ""
+	}
+	return *input
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+var (
+	tlsCaFiles               = flag.String("tls-caFiles", "", "Comma separated list of PEM files with CA certificates which are trusted in addition to the ones of the system.")
+	tlsClientCertificateFile = flag.String("tls-clientCertificate", "", "PEM file with the client certificate to use for mutual TLS.")
+	tlsClientKeyFile         = flag.String("tls-clientKey", "", "PEM file with the private key of tls-clientCertificate.")
+)
+
+// transportConfiguration describes the TLS settings of the transport which is
+// used to talk to a provider or to download assets.
+type transportConfiguration struct {
+	// CaFiles are PEM files with CA certificates which are trusted in
+	// addition to the ones of the system.
+	CaFiles []string `yaml:"caFiles"`
+	// ClientCertificate and ClientKey are PEM files which are used for
+	// mutual TLS, for example with a self-hosted GitLab.
+	ClientCertificate string `yaml:"clientCertificate"`
+	ClientKey         string `yaml:"clientKey"`
+}
+
+// defaultTransportConfiguration is derived from the tls-* flags.
+func defaultTransportConfiguration() transportConfiguration {
+	var caFiles []string
+	for _, candidate := range strings.Split(*tlsCaFiles, ",") {
+		if candidate = strings.TrimSpace(candidate); candidate != "" {
+			caFiles = append(caFiles, candidate)
+		}
+	}
+	return transportConfiguration{
+		CaFiles:           caFiles,
+		ClientCertificate: *tlsClientCertificateFile,
+		ClientKey:         *tlsClientKeyFile,
+	}
+}
+
+// with returns a copy of this configuration where the given overrides are
+// applied. CaFiles are added; the client certificate is replaced.
+func (instance transportConfiguration) with(overrides *transportConfiguration) transportConfiguration {
+	if overrides == nil {
+		return instance
+	}
+	result := instance
+	result.CaFiles = append(append([]string{}, instance.CaFiles...), overrides.CaFiles...)
+	if overrides.ClientCertificate != "" || overrides.ClientKey != "" {
+		result.ClientCertificate = overrides.ClientCertificate
+		result.ClientKey = overrides.ClientKey
+	}
+	return result
+}
+
+func (instance transportConfiguration) validate() error {
+	if (instance.ClientCertificate == "") != (instance.ClientKey == "") {
+		return fmt.Errorf("clientCertificate and clientKey have to be provided together")
+	}
+	return nil
+}
+
+// newTransport creates a transport which is based on http.DefaultTransport,
+// so it honors HTTP(S)_PROXY/NO_PROXY, supports HTTP/2 and uses its timeouts.
+// The system root CAs are trusted together with the configured CaFiles.
+// Transient errors and rate limits are retried by a retryTransport. If a
+// cache directory is configured, responses are cached by a cacheTransport.
+func (instance transportConfiguration) newTransport() (http.RoundTripper, error) {
+	if err := instance.validate(); err != nil {
+		return nil, err
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	for _, file := range instance.CaFiles {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read CA file '%s': %w", file, err)
+		}
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("CA file '%s' does not contain any PEM encoded certificate", file)
+		}
+	}
+
+	tlsConfig := &tls.Config{
+		RootCAs:    pool,
+		MinVersion: tls.VersionTLS12,
+	}
+	if instance.ClientCertificate != "" {
+		certificate, err := tls.LoadX509KeyPair(instance.ClientCertificate, instance.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load client certificate '%s' with key '%s': %w", instance.ClientCertificate, instance.ClientKey, err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+
+	result := http.DefaultTransport.(*http.Transport).Clone()
+	result.TLSClientConfig = tlsConfig
+	var transport http.RoundTripper = newRetryTransport(result)
+	if *cacheDirectory != "" {
+		transport = newCacheTransport(transport, *cacheDirectory, *offline)
+	}
+	return transport, nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+)
+
+type transportSuite struct{}
+
+var _ = Suite(&transportSuite{})
+
+func (s *transportSuite) TestNewTransportWithCaFiles(c *C) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	caFile := filepath.Join(c.MkDir(), "ca.pem")
+	c.Assert(os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: server.Certificate().Raw,
+	}), 0644), IsNil)
+
+	withoutCa, err := transportConfiguration{}.newTransport()
+	c.Assert(err, IsNil)
+	_, err = (&http.Client{Transport: withoutCa}).Get(server.URL)
+	c.Assert(err, ErrorMatches, ".*certificate.*")
+
+	withCa, err := transportConfiguration{}.with(&transportConfiguration{CaFiles: []string{caFile}}).newTransport()
+	c.Assert(err, IsNil)
+	resp, err := (&http.Client{Transport: withCa}).Get(server.URL)
+	c.Assert(err, IsNil)
+	c.Assert(resp.StatusCode, Equals, http.StatusNoContent)
+	c.Assert(withCa.(*retryTransport).delegate.(*http.Transport).Proxy, NotNil)
+}
+
+func (s *transportSuite) TestNewTransportFailsForIllegalFiles(c *C) {
+	_, err := transportConfiguration{CaFiles: []string{"testdata/does-not-exist.pem"}}.newTransport()
+	c.Assert(err, ErrorMatches, "cannot read CA file.*")
+
+	_, err = transportConfiguration{ClientCertificate: "client.pem"}.newTransport()
+	c.Assert(err, ErrorMatches, "clientCertificate and clientKey have to be provided together")
+}
+
+func (s *transportSuite) TestWith(c *C) {
+	instance := transportConfiguration{CaFiles: []string{"a.pem"}, ClientCertificate: "c.pem", ClientKey: "k.pem"}
+
+	c.Assert(instance.with(nil), DeepEquals, instance)
+	c.Assert(instance.with(&transportConfiguration{CaFiles: []string{"b.pem"}}), DeepEquals, transportConfiguration{
+		CaFiles:           []string{"a.pem", "b.pem"},
+		ClientCertificate: "c.pem",
+		ClientKey:         "k.pem",
+	})
+	c.Assert(instance.with(&transportConfiguration{ClientCertificate: "other.pem", ClientKey: "other.key"}).ClientCertificate, Equals, "other.pem")
+}